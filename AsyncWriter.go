@@ -0,0 +1,244 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DEFAULT_QUEUE_SIZE = 512
+const DEFAULT_FLUSH_INTERVAL = time.Second
+
+// A FrameWriter writes data frames to a Frame Streams destination. It is
+// implemented by Writer, ReconnectingWriter and AsyncWriter.
+type FrameWriter interface {
+	WriteFrame(frame []byte) (int, error)
+	Flush() error
+	Close() error
+}
+
+// QueuePolicy selects what an AsyncWriter does with a frame written while
+// its queue is full.
+type QueuePolicy int
+
+const (
+	// DropNewest discards the frame being written.
+	DropNewest QueuePolicy = iota
+	// DropOldest discards the oldest queued frame to make room.
+	DropOldest
+	// Block waits for room in the queue.
+	Block
+)
+
+// AsyncWriterOptions specifies configuration for an AsyncWriter.
+type AsyncWriterOptions struct {
+	// QueueSize is the number of frames which may be queued. It
+	// defaults to DEFAULT_QUEUE_SIZE.
+	QueueSize int
+	// Policy determines what happens to frames written while the queue
+	// is full.
+	Policy QueuePolicy
+	// FlushInterval is the longest a written frame waits in the
+	// underlying Writer's buffer before it is flushed. It defaults to
+	// DEFAULT_FLUSH_INTERVAL.
+	FlushInterval time.Duration
+	// If FlushFrames is nonzero, the underlying Writer is also flushed
+	// after every FlushFrames frames.
+	FlushFrames int
+}
+
+// An AsyncWriter queues data frames for a background goroutine to write to
+// an underlying FrameWriter, so that WriteFrame does not wait for I/O.
+type AsyncWriter struct {
+	w       FrameWriter
+	opt     AsyncWriterOptions
+	queue   chan []byte
+	flushes chan chan error
+	done    chan struct{}
+
+	mu      sync.RWMutex
+	closed  bool
+	dropped uint64
+
+	errMu sync.Mutex
+	err   error
+}
+
+// NewAsyncWriter returns an AsyncWriter writing to w, and starts its
+// background goroutine. The AsyncWriter takes ownership of w; it must not
+// be used directly afterward.
+func NewAsyncWriter(w FrameWriter, opt *AsyncWriterOptions) *AsyncWriter {
+	if opt == nil {
+		opt = &AsyncWriterOptions{}
+	}
+	aw := &AsyncWriter{
+		w:       w,
+		opt:     *opt,
+		flushes: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	if aw.opt.QueueSize <= 0 {
+		aw.opt.QueueSize = DEFAULT_QUEUE_SIZE
+	}
+	if aw.opt.FlushInterval <= 0 {
+		aw.opt.FlushInterval = DEFAULT_FLUSH_INTERVAL
+	}
+	aw.queue = make(chan []byte, aw.opt.QueueSize)
+	go aw.run()
+	return aw
+}
+
+// WriteFrame queues a copy of frame for writing. If the queue is full and
+// the policy is DropNewest, WriteFrame discards the frame and returns
+// ErrQueueFull.
+func (aw *AsyncWriter) WriteFrame(frame []byte) (int, error) {
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+	if aw.closed {
+		return 0, ErrWriterClosed
+	}
+
+	f := append([]byte(nil), frame...)
+	switch aw.opt.Policy {
+	case Block:
+		aw.queue <- f
+	case DropOldest:
+		for {
+			select {
+			case aw.queue <- f:
+				return len(frame), nil
+			default:
+			}
+			select {
+			case <-aw.queue:
+				atomic.AddUint64(&aw.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case aw.queue <- f:
+		default:
+			atomic.AddUint64(&aw.dropped, 1)
+			return 0, ErrQueueFull
+		}
+	}
+	return len(frame), nil
+}
+
+// Flush waits until the frames queued before the call have been written and
+// the underlying FrameWriter flushed.
+func (aw *AsyncWriter) Flush() error {
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+	if aw.closed {
+		return ErrWriterClosed
+	}
+	res := make(chan error)
+	aw.flushes <- res
+	return <-res
+}
+
+// Close writes all queued frames, then closes the underlying FrameWriter.
+// It returns the first error encountered by the background goroutine.
+func (aw *AsyncWriter) Close() error {
+	aw.mu.Lock()
+	if aw.closed {
+		aw.mu.Unlock()
+		return ErrWriterClosed
+	}
+	aw.closed = true
+	close(aw.queue)
+	aw.mu.Unlock()
+
+	<-aw.done
+	return aw.Err()
+}
+
+// Dropped returns the number of frames discarded because the queue was
+// full.
+func (aw *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&aw.dropped)
+}
+
+// Err returns the first error encountered writing to the underlying
+// FrameWriter, if any.
+func (aw *AsyncWriter) Err() error {
+	aw.errMu.Lock()
+	defer aw.errMu.Unlock()
+	return aw.err
+}
+
+func (aw *AsyncWriter) setErr(err error) {
+	if err == nil {
+		return
+	}
+	aw.errMu.Lock()
+	if aw.err == nil {
+		aw.err = err
+	}
+	aw.errMu.Unlock()
+}
+
+func (aw *AsyncWriter) run() {
+	defer close(aw.done)
+	ticker := time.NewTicker(aw.opt.FlushInterval)
+	defer ticker.Stop()
+
+	pending := 0
+	write := func(f []byte) {
+		_, err := aw.w.WriteFrame(f)
+		aw.setErr(err)
+		pending++
+		if aw.opt.FlushFrames > 0 && pending >= aw.opt.FlushFrames {
+			aw.setErr(aw.w.Flush())
+			pending = 0
+		}
+	}
+
+	for {
+		select {
+		case f, ok := <-aw.queue:
+			if !ok {
+				aw.setErr(aw.w.Close())
+				return
+			}
+			write(f)
+		case res := <-aw.flushes:
+		drain:
+			for n := len(aw.queue); n > 0; n-- {
+				select {
+				case f := <-aw.queue:
+					write(f)
+				default:
+					break drain
+				}
+			}
+			err := aw.w.Flush()
+			aw.setErr(err)
+			pending = 0
+			res <- err
+		case <-ticker.C:
+			if pending > 0 {
+				aw.setErr(aw.w.Flush())
+				pending = 0
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package framestream_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w, err := framestream.NewWriter(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aw := framestream.NewAsyncWriter(w, &framestream.AsyncWriterOptions{
+		Policy:      framestream.Block,
+		QueueSize:   2,
+		FlushFrames: 3,
+	})
+	for i := 1; i < 10; i++ {
+		if _, err := aw.WriteFrame(make([]byte, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Close must drain the queue before writing CONTROL_STOP.
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := aw.WriteFrame([]byte("closed")); err != framestream.ErrWriterClosed {
+		t.Errorf("WriteFrame after Close returned %v", err)
+	}
+
+	dec, err := framestream.NewDecoder(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDecoder(t, dec, 9)
+}
+
+// stalledWriter blocks each write until released.
+type stalledWriter struct {
+	entered chan struct{}
+	release chan struct{}
+	frames  [][]byte
+}
+
+func (s *stalledWriter) WriteFrame(b []byte) (int, error) {
+	select {
+	case s.entered <- struct{}{}:
+	default:
+	}
+	<-s.release
+	s.frames = append(s.frames, b)
+	return len(b), nil
+}
+
+func (s *stalledWriter) Flush() error { return nil }
+func (s *stalledWriter) Close() error { return nil }
+
+func testAsyncWriterDrop(t *testing.T, policy framestream.QueuePolicy, expected []string) {
+	sw := &stalledWriter{
+		entered: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	aw := framestream.NewAsyncWriter(sw, &framestream.AsyncWriterOptions{
+		Policy:    policy,
+		QueueSize: 2,
+	})
+
+	// The first frame is taken by the background goroutine, which then
+	// stalls. The next two fill the queue, and the rest overflow it.
+	aw.WriteFrame([]byte("0"))
+	<-sw.entered
+	for _, f := range []string{"1", "2"} {
+		if _, err := aw.WriteFrame([]byte(f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"3", "4"} {
+		_, err := aw.WriteFrame([]byte(f))
+		if policy == framestream.DropNewest && err != framestream.ErrQueueFull {
+			t.Errorf("WriteFrame(%s) returned %v, expected %v", f, err, framestream.ErrQueueFull)
+		}
+	}
+	close(sw.release)
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if aw.Dropped() != 2 {
+		t.Errorf("dropped %d frames, expected 2", aw.Dropped())
+	}
+	var got []string
+	for _, f := range sw.frames {
+		got = append(got, string(f))
+	}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("wrote %v, expected %v", got, expected)
+	}
+}
+
+func TestAsyncWriterDropNewest(t *testing.T) {
+	testAsyncWriterDrop(t, framestream.DropNewest, []string{"0", "1", "2"})
+}
+
+func TestAsyncWriterDropOldest(t *testing.T) {
+	testAsyncWriterDrop(t, framestream.DropOldest, []string{"0", "3", "4"})
+}
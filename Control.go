@@ -4,7 +4,10 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"log/slog"
+	"sync"
 )
 
 const CONTROL_ACCEPT = 0x01
@@ -20,6 +23,101 @@ const CONTROL_FRAME_LENGTH_MAX = 512
 type ControlFrame struct {
 	ControlType  uint32
 	ContentTypes [][]byte
+	// Fields holds the fields of types other than
+	// CONTROL_FIELD_CONTENT_TYPE, in the order received. They are
+	// encoded after the content types.
+	Fields []ControlField
+
+	// Storage reused by Decode.
+	buf    []byte
+	types  [][]byte
+	fields []ControlField
+}
+
+// A ControlField is a control frame field of a type other than
+// CONTROL_FIELD_CONTENT_TYPE.
+type ControlField struct {
+	Type  uint32
+	Value []byte
+}
+
+// Direction gives whether a control frame was sent to or received from
+// the peer.
+type Direction int
+
+const (
+	DirectionReceived Direction = iota
+	DirectionSent
+)
+
+func (d Direction) String() string {
+	if d == DirectionSent {
+		return "sent"
+	}
+	return "received"
+}
+
+// observeControl passes a control frame, before it is sent or after it is
+// received, to the OnControlFrame hook of a Reader or Writer, then records
+// it with m and l unless the hook rejects it. The hook's error is returned
+// as a *ProtocolError at pos if the frame was received. pos is nil for a
+// Writer which receives no frames.
+func observeControl(hook func(Direction, *ControlFrame) error, m Metrics, l *slog.Logger,
+	pos *streamPos, cf *ControlFrame, dir Direction, phase Phase) error {
+	if hook != nil {
+		if err := hook(dir, cf); err != nil {
+			if dir == DirectionReceived {
+				return pos.newError(err, phase)
+			}
+			return err
+		}
+	}
+	if m != nil {
+		m.ControlFrame(cf.ControlType, dir == DirectionSent)
+	}
+	var off int64
+	if pos != nil {
+		off = pos.off
+	}
+	logControl(l, cf, dir, off)
+	return nil
+}
+
+var fieldRegistry = struct {
+	sync.RWMutex
+	validators map[uint32]func(controlType uint32, value []byte) error
+}{validators: make(map[uint32]func(uint32, []byte) error)}
+
+// RegisterControlField registers a function validating the value of
+// control fields of type fieldType. Decode calls it for each field of the
+// type, and fails with ErrDecode if it returns an error. Fields of
+// unregistered types are accepted without validation.
+//
+// RegisterControlField panics if fieldType is CONTROL_FIELD_CONTENT_TYPE or
+// is already registered.
+func RegisterControlField(fieldType uint32, validate func(controlType uint32, value []byte) error) {
+	if fieldType == CONTROL_FIELD_CONTENT_TYPE {
+		panic("framestream: cannot register CONTROL_FIELD_CONTENT_TYPE")
+	}
+	fieldRegistry.Lock()
+	defer fieldRegistry.Unlock()
+	if _, ok := fieldRegistry.validators[fieldType]; ok {
+		panic(fmt.Sprintf("framestream: control field type %d registered twice", fieldType))
+	}
+	fieldRegistry.validators[fieldType] = validate
+}
+
+func validateControlField(controlType uint32, f ControlField) error {
+	fieldRegistry.RLock()
+	validate := fieldRegistry.validators[f.Type]
+	fieldRegistry.RUnlock()
+	if validate == nil {
+		return nil
+	}
+	if err := validate(controlType, f.Value); err != nil {
+		return fmt.Errorf("%w: control field %d: %w", ErrDecode, f.Type, err)
+	}
+	return nil
 }
 
 var ControlStart = ControlFrame{ControlType: CONTROL_START}
@@ -28,6 +126,9 @@ var ControlReady = ControlFrame{ControlType: CONTROL_READY}
 var ControlAccept = ControlFrame{ControlType: CONTROL_ACCEPT}
 var ControlFinish = ControlFrame{ControlType: CONTROL_FINISH}
 
+// Encode writes the control frame, with its escape sequence, to w. It
+// returns ErrControlFrameTooLarge if the frame would exceed
+// CONTROL_FRAME_LENGTH_MAX bytes, which Decode rejects.
 func (c *ControlFrame) Encode(w io.Writer) (err error) {
 	var buf bytes.Buffer
 	err = binary.Write(&buf, binary.BigEndian, c.ControlType)
@@ -50,6 +151,26 @@ func (c *ControlFrame) Encode(w io.Writer) (err error) {
 			return
 		}
 	}
+	for _, f := range c.Fields {
+		err = binary.Write(&buf, binary.BigEndian, f.Type)
+		if err != nil {
+			return
+		}
+
+		err = binary.Write(&buf, binary.BigEndian, uint32(len(f.Value)))
+		if err != nil {
+			return
+		}
+
+		_, err = buf.Write(f.Value)
+		if err != nil {
+			return
+		}
+	}
+
+	if buf.Len() > CONTROL_FRAME_LENGTH_MAX {
+		return ErrControlFrameTooLarge
+	}
 
 	err = binary.Write(w, binary.BigEndian, uint32(0))
 	if err != nil {
@@ -70,12 +191,20 @@ func (c *ControlFrame) EncodeFlush(w *bufio.Writer) error {
 	return w.Flush()
 }
 
+// Decode reads a control frame following its escape sequence. The
+// ContentTypes and Fields decoded refer to storage which is reused by the
+// next call to Decode on the same ControlFrame.
 func (c *ControlFrame) Decode(r io.Reader) (err error) {
-	var cflen uint32
-	err = binary.Read(r, binary.BigEndian, &cflen)
+	if cap(c.buf) < CONTROL_FRAME_LENGTH_MAX {
+		c.buf = make([]byte, CONTROL_FRAME_LENGTH_MAX)
+	}
+	buf := c.buf[:CONTROL_FRAME_LENGTH_MAX]
+
+	_, err = io.ReadFull(r, buf[:4])
 	if err != nil {
 		return
 	}
+	cflen := binary.BigEndian.Uint32(buf[:4])
 
 	if cflen > CONTROL_FRAME_LENGTH_MAX {
 		return ErrDecode
@@ -85,25 +214,21 @@ func (c *ControlFrame) Decode(r io.Reader) (err error) {
 		return ErrDecode
 	}
 
-	err = binary.Read(r, binary.BigEndian, &c.ControlType)
+	_, err = io.ReadFull(r, buf[:cflen])
 	if err != nil {
 		return
 	}
+	c.ControlType = binary.BigEndian.Uint32(buf[:4])
 
-	cflen -= 4
-	if cflen > 0 {
-		cfields := make([]byte, int(cflen))
-		_, err = io.ReadFull(r, cfields)
-		if err != nil {
-			return
-		}
-
-		for len(cfields) > 8 {
+	c.ContentTypes = nil
+	c.Fields = nil
+	cfields := buf[4:cflen]
+	if len(cfields) > 0 {
+		types := c.types[:0]
+		fields := c.fields[:0]
+		for len(cfields) >= 8 {
 			cftype := binary.BigEndian.Uint32(cfields[:4])
 			cfields = cfields[4:]
-			if cftype != CONTROL_FIELD_CONTENT_TYPE {
-				return ErrDecode
-			}
 
 			cflen := int(binary.BigEndian.Uint32(cfields[:4]))
 			cfields = cfields[4:]
@@ -111,24 +236,52 @@ func (c *ControlFrame) Decode(r io.Reader) (err error) {
 				return ErrDecode
 			}
 
-			c.ContentTypes = append(c.ContentTypes, cfields[:cflen])
+			if cftype == CONTROL_FIELD_CONTENT_TYPE {
+				types = append(types, cfields[:cflen])
+			} else {
+				f := ControlField{Type: cftype, Value: cfields[:cflen]}
+				if err = validateControlField(c.ControlType, f); err != nil {
+					return
+				}
+				fields = append(fields, f)
+			}
 			cfields = cfields[cflen:]
 		}
 
 		if len(cfields) > 0 {
 			return ErrDecode
 		}
+		if len(types) > 0 {
+			c.ContentTypes, c.types = types, types
+		}
+		if len(fields) > 0 {
+			c.Fields, c.fields = fields, fields
+		}
 	}
 	return
 }
 
+// Field returns the value of the first field of the given type, and whether
+// it was present.
+func (c *ControlFrame) Field(fieldType uint32) ([]byte, bool) {
+	for _, f := range c.Fields {
+		if f.Type == fieldType {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
 func (c *ControlFrame) DecodeEscape(r io.Reader) error {
-	var zero uint32
-	err := binary.Read(r, binary.BigEndian, &zero)
+	if cap(c.buf) < CONTROL_FRAME_LENGTH_MAX {
+		c.buf = make([]byte, CONTROL_FRAME_LENGTH_MAX)
+	}
+	zero := c.buf[:4]
+	_, err := io.ReadFull(r, zero)
 	if err != nil {
 		return err
 	}
-	if zero != 0 {
+	if binary.BigEndian.Uint32(zero) != 0 {
 		return ErrDecode
 	}
 	return c.Decode(r)
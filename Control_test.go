@@ -0,0 +1,246 @@
+package framestream_test
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+func TestControlFrameUnknownFields(t *testing.T) {
+	cf := framestream.ControlReady
+	cf.SetContentTypes(contentTypes("type1", "type2"))
+	cf.Fields = []framestream.ControlField{
+		{Type: 0x7f01, Value: nil},
+		{Type: 0x7f00, Value: []byte("extension")},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := cf.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	encoded := append([]byte(nil), buf.Bytes()...)
+
+	var dec framestream.ControlFrame
+	if err := dec.DecodeEscape(buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(dec.ContentTypes) != 2 || len(dec.Fields) != 2 {
+		t.Fatalf("decoded %d content types and %d fields", len(dec.ContentTypes), len(dec.Fields))
+	}
+	if v, ok := dec.Field(0x7f00); !ok || string(v) != "extension" {
+		t.Errorf("field 0x7f00 = %q, %v", v, ok)
+	}
+	if _, ok := dec.Field(0x7f02); ok {
+		t.Error("absent field found")
+	}
+
+	// Unknown fields survive a round trip.
+	buf.Reset()
+	if err := dec.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), encoded) {
+		t.Errorf("re-encoded %x, expected %x", buf.Bytes(), encoded)
+	}
+}
+
+func TestRegisterControlField(t *testing.T) {
+	const fieldType = 0x7e00
+	errOdd := errors.New("odd length")
+	defer framestream.UnregisterControlField(fieldType)
+	framestream.RegisterControlField(fieldType, func(controlType uint32, value []byte) error {
+		if len(value)%2 != 0 {
+			return errOdd
+		}
+		return nil
+	})
+
+	for _, tc := range []struct {
+		value string
+		err   error
+	}{
+		{"even", nil},
+		{"odd", errOdd},
+	} {
+		cf := framestream.ControlStart
+		cf.Fields = []framestream.ControlField{{Type: fieldType, Value: []byte(tc.value)}}
+		buf := new(bytes.Buffer)
+		cf.Encode(buf)
+
+		var dec framestream.ControlFrame
+		err := dec.DecodeEscape(buf)
+		if tc.err == nil && err != nil {
+			t.Errorf("%s: %v", tc.value, err)
+		}
+		if tc.err != nil && !(errors.Is(err, tc.err) && errors.Is(err, framestream.ErrDecode)) {
+			t.Errorf("%s: Decode returned %v, expected %v wrapping %v",
+				tc.value, err, framestream.ErrDecode, tc.err)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate registration did not panic")
+		}
+	}()
+	framestream.RegisterControlField(fieldType, nil)
+}
+
+// transcript records the control frames passed to an OnControlFrame hook.
+type transcript []string
+
+func (tr *transcript) hook(dir framestream.Direction, cf *framestream.ControlFrame) error {
+	*tr = append(*tr, framestream.ControlTypeName(cf.ControlType)+" "+dir.String())
+	return nil
+}
+
+func TestOnControlFrame(t *testing.T) {
+	var wtr, rtr transcript
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer client.Close()
+		w, err := framestream.NewWriter(client, &framestream.WriterOptions{
+			Bidirectional:  true,
+			ContentTypes:   contentTypes("test"),
+			OnControlFrame: wtr.hook,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.WriteFrame([]byte("frame"))
+		if err := w.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	r, err := framestream.NewReader(server, &framestream.ReaderOptions{
+		Bidirectional:  true,
+		ContentTypes:   contentTypes("test"),
+		OnControlFrame: rtr.hook,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := r.Next(); err != nil {
+			break
+		}
+	}
+	<-done
+
+	checkStrings(t, "Writer transcript", wtr,
+		[]string{"READY sent", "ACCEPT received", "START sent", "STOP sent", "FINISH received"})
+	checkStrings(t, "Reader transcript", rtr,
+		[]string{"READY received", "ACCEPT sent", "START received", "STOP received", "FINISH sent"})
+}
+
+// midStreamControl returns a stream with an unexpected READY frame between
+// its two data frames.
+func midStreamControl(t *testing.T) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	w, err := framestream.NewWriter(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteFrame([]byte("one"))
+	w.Flush()
+	ready := framestream.ControlReady
+	if err := ready.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteFrame([]byte("two"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestOnControlFrameMidStream(t *testing.T) {
+	var tr transcript
+	r, err := framestream.NewReader(midStreamControl(t), &framestream.ReaderOptions{
+		OnControlFrame: tr.hook,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frames []string
+	for {
+		frame, err := r.Next()
+		if err != nil {
+			break
+		}
+		frames = append(frames, string(frame))
+	}
+	checkStrings(t, "frames", frames, []string{"one", "two"})
+	checkStrings(t, "transcript", tr, []string{"START received", "READY received", "STOP received"})
+}
+
+var errRejected = errors.New("rejected")
+
+func TestOnControlFrameReject(t *testing.T) {
+	r, err := framestream.NewReader(midStreamControl(t), &framestream.ReaderOptions{
+		OnControlFrame: func(dir framestream.Direction, cf *framestream.ControlFrame) error {
+			if cf.ControlType == framestream.CONTROL_READY {
+				return errRejected
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame, err := r.Next(); err != nil || string(frame) != "one" {
+		t.Fatalf("Next: %q, %v", frame, err)
+	}
+	_, err = r.Next()
+	var perr *framestream.ProtocolError
+	if !errors.As(err, &perr) || !errors.Is(err, errRejected) {
+		t.Fatalf("expected rejection, received %v", err)
+	}
+	if perr.Phase != framestream.PhaseData || perr.Frame != 2 {
+		t.Errorf("rejected at %s frame %d", perr.Phase, perr.Frame)
+	}
+	// The rejected frame is skipped.
+	if frame, err := r.Next(); err != nil || string(frame) != "two" {
+		t.Fatalf("Next: %q, %v", frame, err)
+	}
+}
+
+func TestOnControlFrameRejectHandshake(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		defer client.Close()
+		framestream.NewReader(client, &framestream.ReaderOptions{Bidirectional: true})
+	}()
+	_, err := framestream.NewWriter(server, &framestream.WriterOptions{
+		Bidirectional: true,
+		OnControlFrame: func(dir framestream.Direction, cf *framestream.ControlFrame) error {
+			if dir == framestream.DirectionReceived {
+				return errRejected
+			}
+			return nil
+		},
+	})
+	var perr *framestream.ProtocolError
+	if !errors.As(err, &perr) || !errors.Is(err, errRejected) || perr.Phase != framestream.PhaseHandshake {
+		t.Fatalf("expected handshake rejection, received %v", err)
+	}
+
+	// Rejecting a frame to be sent fails before it is sent.
+	buf := new(bytes.Buffer)
+	_, err = framestream.NewWriter(buf, &framestream.WriterOptions{
+		OnControlFrame: func(dir framestream.Direction, cf *framestream.ControlFrame) error {
+			return errRejected
+		},
+	})
+	if err != errRejected || buf.Len() != 0 {
+		t.Fatalf("expected rejection with nothing sent, received %v with %d bytes", err, buf.Len())
+	}
+}
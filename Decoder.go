@@ -47,10 +47,11 @@ type DecoderOptions struct {
 
 // A Decoder decodes Frame Streams frames read from an underlying io.Reader.
 //
-// It is provided for compatibility. Use Reader instead.
+// It is provided for compatibility. Use Reader instead. Unlike Reader, it
+// returns errors such as ErrDataFrameTooLarge as they are, rather than
+// wrapped in a *ProtocolError.
 type Decoder struct {
-	buf []byte
-	r   *Reader
+	r *Reader
 }
 
 // NewDecoder returns a Decoder using the given io.Reader and options.
@@ -64,27 +65,24 @@ func NewDecoder(r io.Reader, opt *DecoderOptions) (*Decoder, error) {
 	ropt := &ReaderOptions{
 		Bidirectional: opt.Bidirectional,
 		Timeout:       opt.Timeout,
+		MaxFrameSize:  opt.MaxPayloadSize,
 	}
 	if opt.ContentType != nil {
 		ropt.ContentTypes = append(ropt.ContentTypes, opt.ContentType)
 	}
 	dr, err := NewReader(r, ropt)
 	if err != nil {
-		return nil, err
+		return nil, unwrapProtocolError(err)
 	}
-	dec := &Decoder{
-		buf: make([]byte, opt.MaxPayloadSize),
-		r:   dr,
-	}
-	return dec, nil
+	return &Decoder{r: dr}, nil
 }
 
 // Decode returns the data from a Frame Streams data frame. The slice returned
 // is valid until the next call to Decode.
 func (dec *Decoder) Decode() (frameData []byte, err error) {
-	n, err := dec.r.ReadFrame(dec.buf)
+	frameData, err = dec.r.Next()
 	if err != nil {
-		return nil, err
+		return nil, unwrapProtocolError(err)
 	}
-	return dec.buf[:n], nil
+	return frameData, nil
 }
@@ -40,7 +40,9 @@ type EncoderOptions struct {
 
 // An Encoder sends data frames over a FrameStream Writer.
 //
-// Encoder is provided for compatibility, use Writer instead.
+// Encoder is provided for compatibility, use Writer instead. Unlike Writer,
+// it returns errors such as ErrContentTypeMismatch as they are, rather than
+// wrapped in a *ProtocolError.
 type Encoder struct {
 	*Writer
 }
@@ -60,7 +62,7 @@ func NewEncoder(w io.Writer, opt *EncoderOptions) (enc *Encoder, err error) {
 	}
 	writer, err := NewWriter(w, wopt)
 	if err != nil {
-		return nil, err
+		return nil, unwrapProtocolError(err)
 	}
 	return &Encoder{Writer: writer}, nil
 }
@@ -68,3 +70,8 @@ func NewEncoder(w io.Writer, opt *EncoderOptions) (enc *Encoder, err error) {
 func (e *Encoder) Write(frame []byte) (int, error) {
 	return e.WriteFrame(frame)
 }
+
+// Close flushes and closes the underlying Writer.
+func (e *Encoder) Close() error {
+	return unwrapProtocolError(e.Writer.Close())
+}
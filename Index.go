@@ -0,0 +1,334 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// indexMagic begins an encoded Index, followed by a version number.
+const indexMagic = "FSIX"
+const indexVersion = 1
+
+// maxIndexInterval is the largest index interval, far beyond any useful
+// one, so that an encoded Index with a corrupt interval is rejected.
+const maxIndexInterval = 1 << 32
+
+// An Index records the byte offsets of every Interval'th data frame in a
+// Frame Streams file, allowing an IndexedReader to start reading at any
+// data frame without decoding the frames before it.
+type Index struct {
+	// Interval is the number of data frames between indexed frames.
+	Interval int64
+	// Size is the size of the file when it was indexed.
+	Size int64
+	// Header holds the encoded START frame of the file.
+	Header []byte
+	// Frames is the number of data frames in the file.
+	Frames int64
+	// Offsets holds the byte offsets of data frames 0, Interval,
+	// 2*Interval and so on.
+	Offsets []int64
+}
+
+// BuildIndex reads the Frame Streams file of the given size from r and
+// returns an Index of every interval'th data frame.
+func BuildIndex(r io.ReaderAt, size int64, interval int64) (*Index, error) {
+	if interval <= 0 || interval > maxIndexInterval {
+		return nil, fmt.Errorf("invalid index interval %d", interval)
+	}
+	header, err := readHeader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	idx := &Index{Interval: interval, Size: size, Header: header}
+
+	fr, err := NewReader(io.NewSectionReader(r, 0, size), nil)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		_, _, err := fr.NextFrameReader()
+		if err == EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if idx.Frames%interval == 0 {
+			idx.Offsets = append(idx.Offsets, fr.Offset())
+		}
+		idx.Frames++
+	}
+	return idx, nil
+}
+
+// readHeader returns the encoded START frame at the beginning of a file.
+func readHeader(r io.ReaderAt, size int64) ([]byte, error) {
+	var hdr [8]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[4:])
+	if binary.BigEndian.Uint32(hdr[:4]) != 0 || n > MAX_CONTROL_FRAME_SIZE || int64(n)+8 > size {
+		return nil, ErrDecode
+	}
+	header := make([]byte, 8+n)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// entries returns the number of offsets held by an Index of Frames data
+// frames.
+func (idx *Index) entries() int64 {
+	n := idx.Frames / idx.Interval
+	if idx.Frames%idx.Interval != 0 {
+		n++
+	}
+	return n
+}
+
+// validate returns an error if the fields of idx are inconsistent, as they
+// may be in an Index built by hand.
+func (idx *Index) validate() error {
+	if idx.Interval <= 0 || idx.Interval > maxIndexInterval {
+		return fmt.Errorf("invalid index interval %d", idx.Interval)
+	}
+	if idx.Frames < 0 || int64(len(idx.Offsets)) != idx.entries() {
+		return fmt.Errorf("invalid index: %d offsets for %d frames at interval %d",
+			len(idx.Offsets), idx.Frames, idx.Interval)
+	}
+	return nil
+}
+
+// Check returns an error wrapping ErrIndexMismatch if the file of the given
+// size read by r is not the file indexed by idx, having a different size or
+// START frame.
+func (idx *Index) Check(r io.ReaderAt, size int64) error {
+	if size != idx.Size {
+		return fmt.Errorf("%w: file size %d, indexed %d", ErrIndexMismatch, size, idx.Size)
+	}
+	header, err := readHeader(r, size)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(header, idx.Header) {
+		return fmt.Errorf("%w: START frame differs", ErrIndexMismatch)
+	}
+	return nil
+}
+
+// WriteTo writes the Index to w in a form read by ReadIndex, suitable for
+// a sidecar file.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var b [8]byte
+	put32 := func(v uint32) {
+		binary.BigEndian.PutUint32(b[:4], v)
+		bw.Write(b[:4])
+	}
+	put64 := func(v int64) {
+		binary.BigEndian.PutUint64(b[:], uint64(v))
+		bw.Write(b[:])
+	}
+
+	bw.WriteString(indexMagic)
+	put32(indexVersion)
+	put64(idx.Interval)
+	put64(idx.Size)
+	put64(idx.Frames)
+	put32(uint32(len(idx.Header)))
+	bw.Write(idx.Header)
+	put64(int64(len(idx.Offsets)))
+	for _, off := range idx.Offsets {
+		put64(off)
+	}
+
+	n := int64(len(indexMagic) + 4 + 3*8 + 4 + len(idx.Header) + 8 + 8*len(idx.Offsets))
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ReadIndex reads an Index written by WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	var b [8]byte
+	var err error
+	get32 := func() uint32 {
+		if err == nil {
+			_, err = io.ReadFull(br, b[:4])
+		}
+		return binary.BigEndian.Uint32(b[:4])
+	}
+	get64 := func() int64 {
+		if err == nil {
+			_, err = io.ReadFull(br, b[:])
+		}
+		return int64(binary.BigEndian.Uint64(b[:]))
+	}
+
+	if _, err = io.ReadFull(br, b[:4]); err != nil || string(b[:4]) != indexMagic {
+		return nil, ErrDecode
+	}
+	if get32() != indexVersion {
+		return nil, ErrDecode
+	}
+	idx := &Index{
+		Interval: get64(),
+		Size:     get64(),
+		Frames:   get64(),
+	}
+	hlen := get32()
+	// Every data frame takes at least 4 bytes of the file.
+	if err != nil || hlen > MAX_CONTROL_FRAME_SIZE+8 ||
+		idx.Interval <= 0 || idx.Interval > maxIndexInterval ||
+		idx.Size < 0 || idx.Frames < 0 || idx.Frames > idx.Size/4 {
+		return nil, ErrDecode
+	}
+	idx.Header = make([]byte, hlen)
+	if _, err = io.ReadFull(br, idx.Header); err != nil {
+		return nil, ErrDecode
+	}
+	n := idx.entries()
+	if get64() != n || err != nil {
+		return nil, ErrDecode
+	}
+	// The offsets are only allocated as they are read, so that a corrupt
+	// Size cannot demand more memory than the input holds.
+	idx.Offsets = make([]int64, 0, min(n, 4096))
+	for i := int64(0); i < n && err == nil; i++ {
+		idx.Offsets = append(idx.Offsets, get64())
+	}
+	if err != nil {
+		return nil, ErrDecode
+	}
+	return idx, nil
+}
+
+// An IndexedReader reads the data frames of a Frame Streams file starting
+// from any data frame, using an Index of the file.
+type IndexedReader struct {
+	r           io.ReaderAt
+	idx         *Index
+	fr          *Reader
+	frame       int64
+	contentType []byte
+	opt         ReaderOptions
+}
+
+// NewIndexedReader returns an IndexedReader reading the file of the given
+// size from r, positioned at the first data frame. It returns an error if
+// the fields of idx are inconsistent, and an error wrapping ErrIndexMismatch
+// if idx does not describe the file. The Bidirectional and Timeout options
+// are ignored.
+func NewIndexedReader(r io.ReaderAt, size int64, idx *Index, opt *ReaderOptions) (*IndexedReader, error) {
+	if err := idx.validate(); err != nil {
+		return nil, err
+	}
+	if err := idx.Check(r, size); err != nil {
+		return nil, err
+	}
+	ir := &IndexedReader{r: r, idx: idx}
+	if opt != nil {
+		ir.opt = *opt
+	}
+	ir.opt.Bidirectional = false
+
+	fr, err := NewReader(io.NewSectionReader(r, 0, size), &ir.opt)
+	if err != nil {
+		return nil, err
+	}
+	ir.fr = fr
+	ir.contentType = fr.ContentType()
+	return ir, nil
+}
+
+// SeekFrame positions the IndexedReader so that the next call to Next
+// returns data frame n, counting from zero. Seeking to the number of frames
+// in the file positions the IndexedReader at its end.
+func (ir *IndexedReader) SeekFrame(n int64) error {
+	if n < 0 || n > ir.idx.Frames {
+		return fmt.Errorf("%w: frame %d of %d", ErrFrameRange, n, ir.idx.Frames)
+	}
+	if n == ir.idx.Frames {
+		ir.fr = ir.readerAt(ir.idx.Size)
+		ir.fr.stopped = true
+		ir.frame = n
+		return nil
+	}
+
+	entry := n / ir.idx.Interval
+	ir.fr = ir.readerAt(ir.idx.Offsets[entry])
+	ir.frame = entry * ir.idx.Interval
+	for ir.frame < n {
+		if _, _, err := ir.fr.NextFrameReader(); err != nil {
+			if err == EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		ir.frame++
+	}
+	return nil
+}
+
+// readerAt returns a Reader of the data frames beginning at offset off.
+func (ir *IndexedReader) readerAt(off int64) *Reader {
+	pos := newStreamPos(io.NewSectionReader(ir.r, off, ir.idx.Size-off))
+	pos.n = off
+	fr := newReader(pos, &ir.opt)
+	fr.contentType = ir.contentType
+	return fr
+}
+
+// Next returns the next data frame, as Reader.Next does.
+func (ir *IndexedReader) Next() ([]byte, error) {
+	frame, err := ir.fr.Next()
+	if err == nil || errors.Is(err, ErrDataFrameTooLarge) {
+		ir.frame++
+	}
+	return frame, err
+}
+
+// Frame returns the index of the data frame to be returned by the next
+// call to Next.
+func (ir *IndexedReader) Frame() int64 {
+	return ir.frame
+}
+
+// Offset returns the byte offset in the file of the frame most recently
+// read.
+func (ir *IndexedReader) Offset() int64 {
+	return ir.fr.Offset()
+}
+
+// ContentType returns the content type of the file.
+func (ir *IndexedReader) ContentType() []byte {
+	return ir.contentType
+}
@@ -0,0 +1,189 @@
+package framestream_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+func TestIndexedReader(t *testing.T) {
+	file := testStream(t, "test", frameSizes(100)...).Bytes()
+	idx, err := framestream.BuildIndex(bytes.NewReader(file), int64(len(file)), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Frames != 100 || len(idx.Offsets) != 15 {
+		t.Fatalf("indexed %d frames with %d offsets", idx.Frames, len(idx.Offsets))
+	}
+
+	// Round trip through the sidecar encoding.
+	enc := new(bytes.Buffer)
+	n, err := idx.WriteTo(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(enc.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, enc.Len())
+	}
+	idx, err = framestream.ReadIndex(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ir, err := framestream.NewIndexedReader(bytes.NewReader(file), int64(len(file)), idx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ir.ContentType()) != "test" {
+		t.Errorf("content type %q", ir.ContentType())
+	}
+	for _, start := range []int64{0, 1, 6, 7, 8, 50, 98, 99} {
+		if err := ir.SeekFrame(start); err != nil {
+			t.Fatalf("SeekFrame(%d): %v", start, err)
+		}
+		for i := start; i < 100; i++ {
+			frame, err := ir.Next()
+			if err != nil {
+				t.Fatalf("frame %d after SeekFrame(%d): %v", i, start, err)
+			}
+			if int64(len(frame)) != i+1 {
+				t.Fatalf("frame %d after SeekFrame(%d): length %d", i, start, len(frame))
+			}
+		}
+		if _, err := ir.Next(); err != framestream.EOF {
+			t.Fatalf("after SeekFrame(%d): expected EOF, received %v", start, err)
+		}
+	}
+
+	if err := ir.SeekFrame(100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ir.Next(); err != framestream.EOF {
+		t.Errorf("after SeekFrame(100): expected EOF, received %v", err)
+	}
+	if err := ir.SeekFrame(101); !errors.Is(err, framestream.ErrFrameRange) {
+		t.Errorf("SeekFrame(101): %v", err)
+	}
+}
+
+func TestIndexedReaderOptions(t *testing.T) {
+	file := testStream(t, "test", frameSizes(10)...).Bytes()
+	idx, err := framestream.BuildIndex(bytes.NewReader(file), int64(len(file)), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tr transcript
+	ir, err := framestream.NewIndexedReader(bytes.NewReader(file), int64(len(file)), idx,
+		&framestream.ReaderOptions{OnControlFrame: tr.hook})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ir.SeekFrame(5); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := ir.Next(); err != nil {
+			break
+		}
+	}
+	// The options apply to the frames read after seeking.
+	checkStrings(t, "transcript", tr, []string{"START received", "STOP received"})
+}
+
+func TestIndexMismatch(t *testing.T) {
+	file := testStream(t, "test", frameSizes(10)...).Bytes()
+	idx, err := framestream.BuildIndex(bytes.NewReader(file), int64(len(file)), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A file of another size.
+	longer := testStream(t, "test", frameSizes(11)...).Bytes()
+	_, err = framestream.NewIndexedReader(bytes.NewReader(longer), int64(len(longer)), idx, nil)
+	if !errors.Is(err, framestream.ErrIndexMismatch) {
+		t.Errorf("longer file: %v", err)
+	}
+
+	// A file of the same size with another START frame.
+	other := testStream(t, "tsst", frameSizes(10)...).Bytes()
+	_, err = framestream.NewIndexedReader(bytes.NewReader(other), int64(len(other)), idx, nil)
+	if !errors.Is(err, framestream.ErrIndexMismatch) {
+		t.Errorf("other content type: %v", err)
+	}
+}
+
+func TestNewIndexedReaderInconsistent(t *testing.T) {
+	file := testStream(t, "test", frameSizes(10)...).Bytes()
+	for _, tc := range []struct {
+		name   string
+		modify func(*framestream.Index)
+	}{
+		{"missing offsets", func(idx *framestream.Index) { idx.Offsets = idx.Offsets[:2] }},
+		{"extra frames", func(idx *framestream.Index) { idx.Frames = 20 }},
+		{"zero interval", func(idx *framestream.Index) { idx.Interval = 0 }},
+		{"larger interval", func(idx *framestream.Index) { idx.Interval = 5 }},
+	} {
+		idx, err := framestream.BuildIndex(bytes.NewReader(file), int64(len(file)), 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.modify(idx)
+		if _, err := framestream.NewIndexedReader(bytes.NewReader(file), int64(len(file)), idx, nil); err == nil {
+			t.Errorf("%s: index accepted", tc.name)
+		}
+	}
+}
+
+func TestReadIndexCorrupt(t *testing.T) {
+	file := testStream(t, "test", frameSizes(10)...).Bytes()
+	idx, err := framestream.BuildIndex(bytes.NewReader(file), int64(len(file)), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := new(bytes.Buffer)
+	if _, err := idx.WriteTo(enc); err != nil {
+		t.Fatal(err)
+	}
+	b := enc.Bytes()
+	for n := 0; n < len(b); n++ {
+		if _, err := framestream.ReadIndex(bytes.NewReader(b[:n])); err == nil {
+			t.Fatalf("index truncated to %d bytes read without error", n)
+		}
+	}
+}
+
+func TestReadIndexHostile(t *testing.T) {
+	file := testStream(t, "test", frameSizes(10)...).Bytes()
+	idx, err := framestream.BuildIndex(bytes.NewReader(file), int64(len(file)), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := new(bytes.Buffer)
+	if _, err := idx.WriteTo(enc); err != nil {
+		t.Fatal(err)
+	}
+	countOff := 36 + len(idx.Header)
+
+	for _, tc := range []struct {
+		name                          string
+		interval, size, frames, count int64
+	}{
+		{"frames", 1, 1 << 62, 1 << 60, 1 << 60},
+		{"frames beyond size", 1, int64(len(file)), int64(len(file)), int64(len(file))},
+		{"interval", math.MaxInt64, int64(len(file)), 10, 1},
+		{"negative size", 1, -1, 0, 0},
+	} {
+		b := append([]byte(nil), enc.Bytes()...)
+		binary.BigEndian.PutUint64(b[8:], uint64(tc.interval))
+		binary.BigEndian.PutUint64(b[16:], uint64(tc.size))
+		binary.BigEndian.PutUint64(b[24:], uint64(tc.frames))
+		binary.BigEndian.PutUint64(b[countOff:], uint64(tc.count))
+		if _, err := framestream.ReadIndex(bytes.NewReader(b)); !errors.Is(err, framestream.ErrDecode) {
+			t.Errorf("%s: expected ErrDecode, received %v", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"context"
+	"io"
+)
+
+// MultiStreamReaderOptions specifies configuration for a
+// MultiStreamReader.
+type MultiStreamReaderOptions struct {
+	ReaderOptions
+	// OnStream, if set, is called at the start of each stream, including
+	// the first, with the stream's index, counting from zero, and its
+	// content type.
+	OnStream func(stream int, contentType []byte)
+}
+
+// A MultiStreamReader reads the data frames of a series of Frame Streams
+// streams, such as concatenated files, or successive streams on a
+// bidirectional connection. Where a Reader ends at a STOP frame, a
+// MultiStreamReader continues with the handshake of the next stream.
+type MultiStreamReader struct {
+	r        *Reader
+	stream   int
+	onStream func(stream int, contentType []byte)
+}
+
+// NewMultiStreamReader returns a MultiStreamReader reading from r, having
+// read the handshake of the first stream.
+func NewMultiStreamReader(r io.Reader, opt *MultiStreamReaderOptions) (*MultiStreamReader, error) {
+	return NewMultiStreamReaderContext(context.Background(), r, opt)
+}
+
+// NewMultiStreamReaderContext is like NewMultiStreamReader, but abandons
+// the first handshake if ctx is done first, as NewReaderContext does.
+func NewMultiStreamReaderContext(ctx context.Context, r io.Reader, opt *MultiStreamReaderOptions) (*MultiStreamReader, error) {
+	if opt == nil {
+		opt = &MultiStreamReaderOptions{}
+	}
+	fr, err := NewReaderContext(ctx, r, &opt.ReaderOptions)
+	if err != nil {
+		return nil, err
+	}
+	m := &MultiStreamReader{r: fr, onStream: opt.OnStream}
+	if m.onStream != nil {
+		m.onStream(0, fr.ContentType())
+	}
+	return m, nil
+}
+
+// Next returns the next data frame, as Reader.Next does, moving on to the
+// next stream at the end of each. It returns EOF when the underlying
+// io.Reader ends after a stream.
+func (m *MultiStreamReader) Next() ([]byte, error) {
+	for {
+		frame, err := m.r.Next()
+		if err != EOF || !m.r.stopped {
+			return frame, err
+		}
+		if err = m.nextStream(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ReadFrame reads the next data frame into b, as Reader.ReadFrame does,
+// moving on to the next stream at the end of each.
+func (m *MultiStreamReader) ReadFrame(b []byte) (int, error) {
+	for {
+		n, err := m.r.ReadFrame(b)
+		if err != EOF || !m.r.stopped {
+			return n, err
+		}
+		if err = m.nextStream(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// NextFrameReader returns the size and contents of the next data frame, as
+// Reader.NextFrameReader does, moving on to the next stream at the end of
+// each.
+func (m *MultiStreamReader) NextFrameReader() (uint32, io.Reader, error) {
+	for {
+		size, body, err := m.r.NextFrameReader()
+		if err != EOF || !m.r.stopped {
+			return size, body, err
+		}
+		if err = m.nextStream(); err != nil {
+			return 0, nil, err
+		}
+	}
+}
+
+func (m *MultiStreamReader) nextStream() error {
+	if err := m.r.nextStream(); err != nil {
+		return err
+	}
+	m.stream++
+	if m.onStream != nil {
+		m.onStream(m.stream, m.r.ContentType())
+	}
+	return nil
+}
+
+// ContentType returns the content type of the current stream.
+func (m *MultiStreamReader) ContentType() []byte {
+	return m.r.ContentType()
+}
+
+// Stream returns the index of the current stream, counting from zero.
+func (m *MultiStreamReader) Stream() int {
+	return m.stream
+}
+
+// Stopped reports whether the current stream has ended with a STOP frame,
+// as Reader.Stopped does. After Next returns EOF, it reports whether the
+// last stream was complete.
+func (m *MultiStreamReader) Stopped() bool {
+	return m.r.Stopped()
+}
+
+// Offset returns the byte offset in the underlying io.Reader of the frame
+// most recently read.
+func (m *MultiStreamReader) Offset() int64 {
+	return m.r.Offset()
+}
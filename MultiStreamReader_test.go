@@ -0,0 +1,193 @@
+package framestream_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+type streamStart struct {
+	stream int
+	ctype  string
+}
+
+// readStreams reads all data frames from m, returning their sizes prefixed
+// with their content type.
+func readStreams(t *testing.T, m *framestream.MultiStreamReader) []string {
+	var frames []string
+	for {
+		frame, err := m.Next()
+		if err == framestream.EOF {
+			return frames
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, string(m.ContentType())+":"+strconv.Itoa(len(frame)))
+	}
+}
+
+func checkStrings(t *testing.T, what string, got, expected []string) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("%s: %q, expected %q", what, got, expected)
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Fatalf("%s: %q, expected %q", what, got, expected)
+		}
+	}
+}
+
+func TestMultiStreamReaderConcatenated(t *testing.T) {
+	var file []byte
+	file = append(file, testStream(t, "a", 1, 2, 3).Bytes()...)
+	file = append(file, testStream(t, "b", 4, 5).Bytes()...)
+	file = append(file, testStream(t, "c").Bytes()...)
+	file = append(file, testStream(t, "d", 6).Bytes()...)
+
+	// A Reader ends with the first stream.
+	r, err := framestream.NewReader(bytes.NewReader(file), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for ; ; n++ {
+		if _, err := r.Next(); err != nil {
+			break
+		}
+	}
+	if n != 3 {
+		t.Errorf("Reader read %d frames, expected 3", n)
+	}
+
+	var starts []streamStart
+	m, err := framestream.NewMultiStreamReader(bytes.NewReader(file), &framestream.MultiStreamReaderOptions{
+		OnStream: func(stream int, ctype []byte) {
+			starts = append(starts, streamStart{stream, string(ctype)})
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := readStreams(t, m)
+	checkStrings(t, "frames", frames, []string{"a:1", "a:2", "a:3", "b:4", "b:5", "d:6"})
+	expected := []streamStart{{0, "a"}, {1, "b"}, {2, "c"}, {3, "d"}}
+	if len(starts) != len(expected) {
+		t.Fatalf("stream starts %v, expected %v", starts, expected)
+	}
+	for i := range starts {
+		if starts[i] != expected[i] {
+			t.Fatalf("stream starts %v, expected %v", starts, expected)
+		}
+	}
+	if m.Stream() != 3 {
+		t.Errorf("Stream() = %d, expected 3", m.Stream())
+	}
+}
+
+func TestMultiStreamReaderUnstopped(t *testing.T) {
+	var file []byte
+	file = append(file, testStream(t, "a", 1).Bytes()...)
+	second := testStream(t, "b", 2).Bytes()
+	// Drop the STOP frame of the second stream.
+	file = append(file, second[:len(second)-stopFrameLen]...)
+
+	m, err := framestream.NewMultiStreamReader(bytes.NewReader(file), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStrings(t, "frames", readStreams(t, m), []string{"a:1", "b:2"})
+	if m.Stopped() {
+		t.Error("unstopped stream reported stopped")
+	}
+}
+
+func TestMultiStreamReaderNextFrameReader(t *testing.T) {
+	var file []byte
+	file = append(file, testStream(t, "a", 1, 2).Bytes()...)
+	file = append(file, testStream(t, "b", 3).Bytes()...)
+
+	m, err := framestream.NewMultiStreamReader(bytes.NewReader(file), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frames []string
+	for {
+		size, body, err := m.NextFrameReader()
+		if err == framestream.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, string(m.ContentType())+":"+strconv.Itoa(int(size)))
+		// Other frames are skipped unread by the next call.
+		if size != 2 {
+			continue
+		}
+		if frame, err := io.ReadAll(body); err != nil || len(frame) != 2 {
+			t.Fatalf("frame body %v, %v", frame, err)
+		}
+	}
+	checkStrings(t, "frames", frames, []string{"a:1", "a:2", "b:3"})
+	if !m.Stopped() || m.Stream() != 1 {
+		t.Errorf("ended in stream %d, stopped %v", m.Stream(), m.Stopped())
+	}
+}
+
+func TestMultiStreamReaderContentTypeMismatch(t *testing.T) {
+	var file []byte
+	file = append(file, testStream(t, "a", 1).Bytes()...)
+	file = append(file, testStream(t, "b", 2).Bytes()...)
+
+	m, err := framestream.NewMultiStreamReader(bytes.NewReader(file), &framestream.MultiStreamReaderOptions{
+		ReaderOptions: framestream.ReaderOptions{ContentTypes: contentTypes("a")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Next(); !errors.Is(err, framestream.ErrContentTypeMismatch) {
+		t.Errorf("expected ErrContentTypeMismatch, received %v", err)
+	}
+}
+
+func TestMultiStreamReaderBidirectional(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		defer client.Close()
+		for i, ctype := range []string{"a", "b"} {
+			w, err := framestream.NewWriter(client, &framestream.WriterOptions{
+				Bidirectional: true,
+				ContentTypes:  contentTypes(ctype),
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := writeStream(w, i+1); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	m, err := framestream.NewMultiStreamReader(server, &framestream.MultiStreamReaderOptions{
+		ReaderOptions: framestream.ReaderOptions{
+			Bidirectional: true,
+			ContentTypes:  contentTypes("a", "b"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStrings(t, "frames", readStreams(t, m), []string{"a:1", "b:2"})
+}
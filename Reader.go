@@ -18,15 +18,20 @@ package framestream
 
 import (
 	"bufio"
+	"context"
 	"encoding/binary"
+	"errors"
 	"io"
 	"io/ioutil"
+	"log/slog"
+	"os"
 	"time"
 )
 
 type ReaderOptions struct {
 	// The ContentTypes accepted by the Reader. May be left unset for no
-	// content negotiation. If the corresponding Writer offers a disjoint
+	// content negotiation, in which case the Reader accepts any content
+	// type the Writer sends. If the corresponding Writer offers a disjoint
 	// set of ContentTypes, NewReader() will return ErrContentTypeMismatch.
 	ContentTypes [][]byte
 	// If Bidirectional is true, the underlying io.Reader must be an
@@ -36,141 +41,455 @@ type ReaderOptions struct {
 	Bidirectional bool
 	// Timeout gives the timeout for reading the initial handshake messages
 	// from the peer and writing response messages if Bidirectional. It is
-	// only effective for underlying Readers satisfying net.Conn.
+	// only effective for underlying Readers supporting deadlines, such as
+	// net.Conn.
 	Timeout time.Duration
+	// MaxFrameSize is the largest data frame returned by Next(). It
+	// defaults to DEFAULT_MAX_PAYLOAD_SIZE.
+	MaxFrameSize uint32
+	// If Follow is true and the underlying io.Reader is an *os.File, the
+	// Reader waits for more data at the end of the file, as "tail -f"
+	// does, until the STOP frame is read. If the file is truncated, or
+	// renamed and replaced, the Reader returns ErrFileTruncated or
+	// ErrFileRotated.
+	Follow bool
+	// FollowInterval gives how often a followed file is checked for more
+	// data. It defaults to 250ms.
+	FollowInterval time.Duration
+	// If Recover is true, the Reader skips damaged regions of the stream
+	// following the handshake rather than failing or returning garbage.
+	// A frame is taken as damaged if its length exceeds MaxFrameSize, if
+	// it is a control frame which does not decode, or if the stream ends
+	// within it. The Reader then scans forward for the next plausible
+	// frame, which must be followed by another. Frames longer than
+	// MaxFrameSize are therefore skipped rather than reported with
+	// ErrDataFrameTooLarge.
+	Recover bool
+	// OnSkip, if set, is called by a recovering Reader with the offset
+	// and length of each damaged region skipped.
+	OnSkip func(offset, length int64)
+	// Metrics, if set, receives measurements of the frames read.
+	Metrics Metrics
+	// Logger, if set, receives a debug level record of each control frame
+	// sent and received, and warn level records of failed handshakes,
+	// oversized frames and truncated streams.
+	Logger *slog.Logger
+	// OnControlFrame, if set, is called with each control frame sent and
+	// received, including control frames other than STOP received between
+	// data frames, which are otherwise ignored. Frames are passed to it
+	// before they are sent. If it returns an error, the frame is rejected
+	// and the handshake or read fails with the error, wrapped in a
+	// *ProtocolError if the frame was received. The ControlFrame is only
+	// valid for the duration of the call.
+	OnControlFrame func(dir Direction, cf *ControlFrame) error
 }
 
 // Reader reads data frames from an underlying io.Reader using the Frame
 // Streams framing protocol.
 type Reader struct {
 	contentType   []byte
+	contentTypes  [][]byte
 	bidirectional bool
 	r             *bufio.Reader
 	w             *bufio.Writer
+	tc            *timeoutConn
 	stopped       bool
+	buf           []byte
+	maxFrameSize  uint32
+	body          *frameBody
+	cf            ControlFrame
+	hdr           [4]byte
+	pos           *streamPos
+	follow        *follower
+	recover       bool
+	onSkip        func(offset, length int64)
+	probe         ControlFrame
+	peekErr       error
+	metrics       Metrics
+	log           *slog.Logger
+	onControl     func(dir Direction, cf *ControlFrame) error
 }
 
 // NewReader creates a Frame Streams Reader reading from the given io.Reader
-// with the given ReaderOptions.
+// with the given ReaderOptions. Handshake errors caused by malformed or
+// unexpected frames are returned as *ProtocolError.
 func NewReader(r io.Reader, opt *ReaderOptions) (*Reader, error) {
+	return NewReaderContext(context.Background(), r, opt)
+}
+
+// NewReaderContext is like NewReader, but abandons the handshake and
+// returns ctx.Err() if ctx is done first. Blocked I/O is only interrupted
+// for underlying Readers supporting deadlines, such as net.Conn.
+func NewReaderContext(ctx context.Context, r io.Reader, opt *ReaderOptions) (*Reader, error) {
 	if opt == nil {
 		opt = &ReaderOptions{}
 	}
-	tr := timeoutReader(r, opt)
-	reader := &Reader{
-		bidirectional: opt.Bidirectional,
-		r:             bufio.NewReader(tr),
-		w:             nil,
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-
-	if len(opt.ContentTypes) > 0 {
-		reader.contentType = opt.ContentTypes[0]
+	var follow *follower
+	if f, ok := r.(*os.File); ok && opt.Follow {
+		follow = newFollower(f, opt.FollowInterval)
+		defer follow.bind(ctx)()
+		r = follow
+	}
+	tr := timeoutReader(r, opt)
+	reader := newReader(newStreamPos(tr), opt)
+	reader.follow = follow
+	if tc, ok := tr.(*timeoutConn); ok {
+		reader.tc = tc
+		defer tc.bind(ctx)()
 	}
 
-	var cf ControlFrame
 	if opt.Bidirectional {
 		w, ok := tr.(io.Writer)
 		if !ok {
 			return nil, ErrType
 		}
 		reader.w = bufio.NewWriter(w)
+	}
+
+	if err := reader.handshake(); err != nil {
+		return nil, contextErr(ctx, err)
+	}
 
+	// Disable the read timeout to prevent killing idle connections.
+	disableReadTimeout(tr)
+
+	return reader, nil
+}
+
+// newReader returns a Reader reading frames from pos with the given
+// options, before any handshake.
+func newReader(pos *streamPos, opt *ReaderOptions) *Reader {
+	reader := &Reader{
+		contentTypes:  opt.ContentTypes,
+		bidirectional: opt.Bidirectional,
+		r:             bufio.NewReader(pos),
+		w:             nil,
+		maxFrameSize:  opt.MaxFrameSize,
+		pos:           pos,
+		recover:       opt.Recover,
+		onSkip:        opt.OnSkip,
+		metrics:       opt.Metrics,
+		log:           newLogger(opt.Logger, "reader"),
+		onControl:     opt.OnControlFrame,
+	}
+	if opt.Recover {
+		reader.r = bufio.NewReaderSize(pos, recoverBufferSize)
+	}
+	if reader.maxFrameSize == 0 {
+		reader.maxFrameSize = DEFAULT_MAX_PAYLOAD_SIZE
+	}
+	return reader
+}
+
+// handshake reads the control frames beginning a stream, replying to them
+// if bidirectional, and sets the stream's content type.
+func (r *Reader) handshake() (err error) {
+	defer func() {
+		if err != nil {
+			logWarn(r.log, "handshake failed", err)
+		}
+	}()
+	began := time.Now()
+	r.contentType = nil
+	if len(r.contentTypes) > 0 {
+		r.contentType = r.contentTypes[0]
+	}
+
+	var cf ControlFrame
+	if r.bidirectional {
 		// Read the ready control frame.
-		err := cf.DecodeTypeEscape(reader.r, CONTROL_READY)
+		err := r.pos.readControl(r.r, &cf, CONTROL_READY, PhaseHandshake)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err = r.control(&cf, DirectionReceived, PhaseHandshake); err != nil {
+			return err
 		}
 
 		// Check content type.
-		if t, ok := cf.ChooseContentType(opt.ContentTypes); ok {
-			reader.contentType = t
+		if t, ok := cf.ChooseContentType(r.contentTypes); ok {
+			r.contentType = t
 		} else {
-			return nil, ErrContentTypeMismatch
+			return r.pos.newError(ErrContentTypeMismatch, PhaseHandshake)
 		}
 
 		// Send the accept control frame.
 		accept := ControlAccept
-		accept.SetContentType(reader.contentType)
-		err = accept.EncodeFlush(reader.w)
+		accept.SetContentType(r.contentType)
+		if err = r.control(&accept, DirectionSent, PhaseHandshake); err != nil {
+			return err
+		}
+		err = accept.EncodeFlush(r.w)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
 	// Read the start control frame.
-	err := cf.DecodeTypeEscape(reader.r, CONTROL_START)
+	err = r.pos.readControl(r.r, &cf, CONTROL_START, PhaseHandshake)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err = r.control(&cf, DirectionReceived, PhaseHandshake); err != nil {
+		return err
 	}
 
-	// Disable the read timeout to prevent killing idle connections.
-	disableReadTimeout(tr)
+	// Check content type. A Reader with no content types configured
+	// accepts the Writer's.
+	if len(r.contentTypes) == 0 && len(cf.ContentTypes) > 0 {
+		r.contentType = append([]byte(nil), cf.ContentTypes[0]...)
+	} else if !cf.MatchContentType(r.contentType) {
+		return r.pos.newError(ErrContentTypeMismatch, PhaseHandshake)
+	}
+	if r.metrics != nil {
+		r.metrics.Handshake(time.Since(began))
+	}
+	return nil
+}
+
+func (r *Reader) control(cf *ControlFrame, dir Direction, phase Phase) error {
+	return observeControl(r.onControl, r.metrics, r.log, r.pos, cf, dir, phase)
+}
 
-	// Check content type.
-	if !cf.MatchContentType(reader.contentType) {
-		return nil, ErrContentTypeMismatch
+// dataError returns err, from reading a data or control frame, as a
+// *ProtocolError if it is one of the protocol errors, logging it if so.
+func (r *Reader) dataError(err error) error {
+	err = r.pos.wrap(err, PhaseData)
+	switch {
+	case err == io.EOF:
+		logWarn(r.log, "stream ended without STOP", nil,
+			slog.Int64("offset", r.pos.off))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		logWarn(r.log, "stream truncated", err)
+	case errors.Is(err, ErrDecode):
+		logWarn(r.log, "malformed frame", err)
 	}
+	return err
+}
 
-	return reader, nil
+// nextStream begins reading the stream following a STOP frame, returning
+// EOF if the underlying io.Reader ends first.
+func (r *Reader) nextStream() error {
+	if _, err := r.r.Peek(1); err != nil {
+		return err
+	}
+	r.stopped = false
+	return r.handshake()
 }
 
 // ReadFrame reads a data frame into the supplied buffer, returning its length.
 // If the frame is longer than the supplied buffer, Read returns
 // ErrDataFrameTooLarge and discards the frame. Subsequent calls to Read()
 // after this error may succeed.
+//
+// Errors caused by malformed frames are returned as *ProtocolError.
 func (r *Reader) ReadFrame(b []byte) (length int, err error) {
-	if r.stopped {
-		return 0, EOF
+	frameLen, err := r.readFrameLen()
+	if err != nil {
+		return 0, err
 	}
 
-	for length == 0 {
-		length, err = r.readFrame(b)
-		if err != nil {
-			return
-		}
+	if frameLen > uint32(len(b)) {
+		return 0, r.discard(frameLen)
+	}
+
+	n, err := r.readBody(b[0:frameLen])
+	if err == nil && r.metrics != nil {
+		r.metrics.DataFrame(n)
+	}
+	return n, err
+}
+
+// Next returns the next data frame. The slice returned is valid until the
+// next call to Next, and refers to a buffer which grows as needed to hold
+// the largest frame read.
+//
+// If the frame is longer than the MaxFrameSize option, Next returns
+// ErrDataFrameTooLarge and discards the frame. Subsequent calls to Next()
+// after this error may succeed.
+func (r *Reader) Next() ([]byte, error) {
+	frameLen, err := r.readFrameLen()
+	if err != nil {
+		return nil, err
 	}
 
-	return
+	if frameLen > r.maxFrameSize {
+		return nil, r.discard(frameLen)
+	}
+
+	if frameLen > uint32(cap(r.buf)) {
+		r.buf = make([]byte, frameLen)
+	}
+	n, err := r.readBody(r.buf[:frameLen])
+	if err == nil && r.metrics != nil {
+		r.metrics.DataFrame(n)
+	}
+	return r.buf[:n], err
 }
 
-// ContentType returns the content type negotiated with the Writer.
-func (r *Reader) ContentType() []byte {
-	return r.contentType
+// discard skips a data frame of the given length, returning
+// ErrDataFrameTooLarge.
+func (r *Reader) discard(frameLen uint32) error {
+	io.CopyN(ioutil.Discard, r.r, int64(frameLen))
+	if r.metrics != nil {
+		r.metrics.FrameTooLarge(frameLen)
+	}
+	perr := r.pos.newError(ErrDataFrameTooLarge, PhaseData)
+	perr.Length = frameLen
+	logWarn(r.log, "data frame too large", perr,
+		slog.Uint64("max_frame_size", uint64(r.maxFrameSize)))
+	return perr
 }
 
-func (r *Reader) readFrame(b []byte) (int, error) {
-	// Read the frame length.
-	var frameLen uint32
-	err := binary.Read(r.r, binary.BigEndian, &frameLen)
+func (r *Reader) readBody(b []byte) (int, error) {
+	n, err := io.ReadFull(r.r, b)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		perr := r.pos.newError(io.ErrUnexpectedEOF, PhaseData)
+		perr.Length = uint32(len(b))
+		logWarn(r.log, "stream truncated", perr)
+		return n, perr
+	}
+	return n, err
+}
+
+// NextFrameReader returns the size of the next data frame and an io.Reader
+// from which its contents may be read, without buffering the frame in
+// memory. The io.Reader is valid until the next call to a method reading
+// from the Reader, which first skips any unread part of the frame.
+func (r *Reader) NextFrameReader() (size uint32, body io.Reader, err error) {
+	size, err = r.readFrameLen()
 	if err != nil {
+		return 0, nil, err
+	}
+	r.body = &frameBody{r: r.r, n: int64(size)}
+	if r.metrics != nil {
+		r.metrics.DataFrame(int(size))
+	}
+	return size, r.body, nil
+}
+
+// ReadFrameContext is like ReadFrame, but returns ctx.Err() if ctx is done
+// before a frame is read. Blocked reads are only interrupted for underlying
+// Readers supporting deadlines, such as net.Conn, and for followed files.
+// If a read is interrupted partway through a frame, the Reader cannot be
+// used further.
+func (r *Reader) ReadFrameContext(ctx context.Context, b []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
 		return 0, err
 	}
+	if r.tc != nil {
+		defer r.tc.bind(ctx)()
+	}
+	if r.follow != nil {
+		defer r.follow.bind(ctx)()
+	}
+	n, err := r.ReadFrame(b)
+	return n, contextErr(ctx, err)
+}
 
-	if frameLen > uint32(len(b)) {
-		io.CopyN(ioutil.Discard, r.r, int64(frameLen))
-		return 0, ErrDataFrameTooLarge
+// ContentType returns the content type negotiated with the Writer. A
+// Reader with no ContentTypes returns the content type the Writer sent.
+func (r *Reader) ContentType() []byte {
+	return r.contentType
+}
+
+// Stopped reports whether the Reader has read the STOP frame ending the
+// stream, rather than reaching the end of the underlying io.Reader first.
+func (r *Reader) Stopped() bool {
+	return r.stopped
+}
+
+// Offset returns the byte offset in the stream of the frame most recently
+// read.
+func (r *Reader) Offset() int64 {
+	return r.pos.off
+}
+
+// readFrameLen returns the length of the next data frame, handling any
+// control frames which precede it.
+func (r *Reader) readFrameLen() (uint32, error) {
+	if r.body != nil {
+		// Skip the rest of the frame from NextFrameReader.
+		n := r.body.n
+		r.body.n = 0
+		r.body = nil
+		if _, err := io.CopyN(ioutil.Discard, r.r, n); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, r.dataError(err)
+		}
 	}
 
-	if frameLen == 0 {
+	for !r.stopped {
+		// Read the frame length.
+		r.pos.begin(r.r.Buffered())
+		if r.recover {
+			if err := r.resync(); err != nil {
+				return 0, err
+			}
+		}
+		_, err := io.ReadFull(r.r, r.hdr[:])
+		if err != nil {
+			return 0, r.dataError(err)
+		}
+		frameLen := binary.BigEndian.Uint32(r.hdr[:])
+
+		if frameLen != 0 {
+			return frameLen, nil
+		}
+
 		// This is a control frame.
-		var cf ControlFrame
-		err = cf.Decode(r.r)
+		err = r.cf.Decode(r.r)
 		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, r.dataError(err)
+		}
+		if err = r.control(&r.cf, DirectionReceived, PhaseData); err != nil {
+			logWarn(r.log, "control frame rejected", err,
+				slog.String("control_type", ControlTypeName(r.cf.ControlType)))
 			return 0, err
 		}
-		if cf.ControlType == CONTROL_STOP {
+		if r.cf.ControlType == CONTROL_STOP {
 			r.stopped = true
 			if r.bidirectional {
 				ff := &ControlFrame{ControlType: CONTROL_FINISH}
+				if err = r.control(ff, DirectionSent, PhaseShutdown); err != nil {
+					logWarn(r.log, "shutdown failed", err)
+					return 0, err
+				}
 				err = ff.EncodeFlush(r.w)
 				if err != nil {
 					return 0, err
 				}
 			}
-			return 0, EOF
 		}
-		return 0, err
 	}
+	return 0, EOF
+}
+
+// frameBody reads the contents of a single data frame.
+type frameBody struct {
+	r *bufio.Reader
+	n int64
+}
 
-	return io.ReadFull(r.r, b[0:frameLen])
+func (fb *frameBody) Read(b []byte) (int, error) {
+	if fb.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > fb.n {
+		b = b[:fb.n]
+	}
+	n, err := fb.r.Read(b)
+	fb.n -= int64(n)
+	if err == io.EOF && fb.n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
 }
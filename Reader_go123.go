@@ -0,0 +1,50 @@
+//go:build go1.23
+
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"errors"
+	"iter"
+)
+
+// Frames returns an iterator over the remaining data frames of the stream,
+// as returned by Next. Each frame is valid only until the next iteration.
+//
+// The iteration ends at the end of the stream. Errors are yielded with a
+// nil frame; iteration continues after ErrDataFrameTooLarge and ends after
+// any other error.
+func (r *Reader) Frames() iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		for {
+			frame, err := r.Next()
+			if err == EOF {
+				return
+			}
+			if err != nil {
+				if !yield(nil, err) || !errors.Is(err, ErrDataFrameTooLarge) {
+					return
+				}
+				continue
+			}
+			if !yield(frame, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+//go:build go1.23
+
+package framestream_test
+
+import (
+	"errors"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+func TestReaderFrames(t *testing.T) {
+	r, err := framestream.NewReader(testStream(t, "", 1, 2, 30, 4),
+		&framestream.ReaderOptions{MaxFrameSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sizes []int
+	var tooLarge int
+	for frame, err := range r.Frames() {
+		if errors.Is(err, framestream.ErrDataFrameTooLarge) {
+			tooLarge++
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, len(frame))
+	}
+	if tooLarge != 1 || len(sizes) != 3 || sizes[0] != 1 || sizes[1] != 2 || sizes[2] != 4 {
+		t.Errorf("received frames of sizes %v and %d oversize frames", sizes, tooLarge)
+	}
+}
@@ -0,0 +1,222 @@
+package framestream_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+// stopFrameLen is the length of an encoded STOP frame, with its escape.
+const stopFrameLen = 12
+
+// testStream returns an encoded stream with the given content type, or
+// none if it is empty, of data frames of the given sizes.
+func testStream(t testing.TB, ctype string, sizes ...int) *bytes.Buffer {
+	var opt framestream.WriterOptions
+	if ctype != "" {
+		opt.ContentTypes = contentTypes(ctype)
+	}
+	buf := new(bytes.Buffer)
+	w, err := framestream.NewWriter(buf, &opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeStream(w, sizes...); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+// writeStream writes data frames of the given sizes, each filled with its
+// size, to w, and closes it.
+func writeStream(w *framestream.Writer, sizes ...int) error {
+	for _, n := range sizes {
+		if _, err := w.WriteFrame(bytes.Repeat([]byte{byte(n)}, n)); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// frameSizes returns the sizes 1 to n.
+func frameSizes(n int) []int {
+	sizes := make([]int, n)
+	for i := range sizes {
+		sizes[i] = i + 1
+	}
+	return sizes
+}
+
+func TestReaderNext(t *testing.T) {
+	r, err := framestream.NewReader(testStream(t, "", 3, 20, 5, 10),
+		&framestream.ReaderOptions{MaxFrameSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{3, 0, 5, 10} {
+		frame, err := r.Next()
+		if n == 0 {
+			if !errors.Is(err, framestream.ErrDataFrameTooLarge) {
+				t.Fatalf("Next returned %v, expected %v", err, framestream.ErrDataFrameTooLarge)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(frame, bytes.Repeat([]byte{byte(n)}, n)) {
+			t.Errorf("received %v, expected %d bytes of %d", frame, n, n)
+		}
+		// The buffer grows only as far as the largest frame seen.
+		if cap(frame) > 10 {
+			t.Errorf("buffer capacity %d after %d byte frame", cap(frame), n)
+		}
+	}
+	if _, err := r.Next(); err != framestream.EOF {
+		t.Errorf("Next returned %v, expected EOF", err)
+	}
+}
+
+func TestNextFrameReader(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w, err := framestream.NewWriter(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := bytes.Repeat([]byte("0123456789"), 100000)
+	for i := 0; i < 3; i++ {
+		n, err := w.WriteFrameFrom(bytes.NewReader(large), uint32(len(large)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(large)) {
+			t.Fatalf("WriteFrameFrom wrote %d bytes, expected %d", n, len(large))
+		}
+	}
+	w.Close()
+
+	r, err := framestream.NewReader(buf, &framestream.ReaderOptions{MaxFrameSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first frame is read in full.
+	size, body, err := r.NextFrameReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != uint32(len(large)) {
+		t.Errorf("frame size %d, expected %d", size, len(large))
+	}
+	got, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, large) {
+		t.Error("frame contents differ")
+	}
+
+	// The second is partly read, and the rest is skipped.
+	_, body, err = r.NextFrameReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(body, make([]byte, 7)); err != nil {
+		t.Fatal(err)
+	}
+	_, body3, err := r.NextFrameReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := body.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("stale frame reader returned %d, %v", n, err)
+	}
+	if _, err := io.ReadFull(body3, make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rest of the third is skipped by Next, which then reaches the
+	// end of the stream.
+	if _, err := r.Next(); err != framestream.EOF {
+		t.Errorf("Next returned %v, expected EOF", err)
+	}
+	if !r.Stopped() {
+		t.Error("Stopped() = false after STOP")
+	}
+}
+
+func TestNextFrameReaderTruncated(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w, err := framestream.NewWriter(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteFrame(make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	buf.Truncate(buf.Len() - 10)
+
+	r, err := framestream.NewReader(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.NextFrameReader(); err != nil {
+		t.Fatal(err)
+	}
+	// Skipping the unread frame finds it truncated.
+	_, _, err = r.NextFrameReader()
+	var perr *framestream.ProtocolError
+	if !errors.As(err, &perr) || perr.Err != io.ErrUnexpectedEOF {
+		t.Errorf("expected unexpected EOF, received %v", err)
+	}
+	if r.Stopped() {
+		t.Error("Stopped() = true without STOP")
+	}
+}
+
+func TestWriteFrameFromShort(t *testing.T) {
+	w, err := framestream.NewWriter(ioutil.Discard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteFrameFrom(bytes.NewReader([]byte("short")), 10); err != io.ErrUnexpectedEOF {
+		t.Errorf("WriteFrameFrom returned %v, expected %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestReaderAdoptsContentType(t *testing.T) {
+	for _, ctype := range []string{"test", ""} {
+		r, err := framestream.NewReader(testStream(t, ctype, 1), nil)
+		if err != nil {
+			t.Fatalf("%q: %v", ctype, err)
+		}
+		if string(r.ContentType()) != ctype {
+			t.Errorf("content type %q, expected %q", r.ContentType(), ctype)
+		}
+	}
+}
+
+func TestReaderOffset(t *testing.T) {
+	r, err := framestream.NewReader(testStream(t, "test", 5, 5), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// START takes 4 + 4 + 4 + 8 + 4 bytes, and each frame 4 + 5.
+	for _, off := range []int64{24, 33} {
+		if _, err := r.Next(); err != nil {
+			t.Fatal(err)
+		}
+		if r.Offset() != off {
+			t.Errorf("frame offset %d, expected %d", r.Offset(), off)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"math/rand"
+	"net"
+	"time"
+)
+
+const DEFAULT_MIN_BACKOFF = 100 * time.Millisecond
+const DEFAULT_MAX_BACKOFF = 30 * time.Second
+
+// ConnState describes the connection state of a ReconnectingWriter.
+type ConnState int
+
+const (
+	StateDisconnected ConnState = iota
+	StateConnected
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnected:
+		return "connected"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// ReconnectingWriterOptions specifies configuration for a ReconnectingWriter.
+type ReconnectingWriterOptions struct {
+	// WriterOptions are used for the Writer created on each connection.
+	WriterOptions
+	// MinBackoff is the delay before the first reconnection attempt after
+	// a failure. Each further failure doubles the delay, up to MaxBackoff.
+	// They default to DEFAULT_MIN_BACKOFF and DEFAULT_MAX_BACKOFF.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Jitter randomizes each delay by up to the given fraction of its
+	// length, in either direction. Values are clamped to [0, 1].
+	Jitter float64
+	// OnStateChange, if set, is called when the connection state changes,
+	// and after each failed connection attempt. err gives the reason for
+	// entering StateDisconnected, and is nil otherwise.
+	OnStateChange func(state ConnState, err error)
+}
+
+// A ReconnectingWriter writes data frames to a connection obtained from a
+// dial function, redialing and repeating the Frame Streams handshake with
+// exponential backoff when the connection fails.
+//
+// Frames written while the ReconnectingWriter is disconnected, and frames
+// buffered in a connection when it fails, are lost.
+type ReconnectingWriter struct {
+	dial    func() (net.Conn, error)
+	opt     ReconnectingWriterOptions
+	conn    net.Conn
+	w       *Writer
+	state   ConnState
+	backoff time.Duration
+	next    time.Time
+	rand    *rand.Rand
+}
+
+// NewReconnectingWriter returns a ReconnectingWriter which obtains
+// connections from dial. It makes the first connection attempt before
+// returning, but does not fail if the attempt does.
+func NewReconnectingWriter(dial func() (net.Conn, error), opt *ReconnectingWriterOptions) *ReconnectingWriter {
+	if opt == nil {
+		opt = &ReconnectingWriterOptions{}
+	}
+	rw := &ReconnectingWriter{
+		dial: dial,
+		opt:  *opt,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if rw.opt.MinBackoff <= 0 {
+		rw.opt.MinBackoff = DEFAULT_MIN_BACKOFF
+	}
+	if rw.opt.MaxBackoff < rw.opt.MinBackoff {
+		rw.opt.MaxBackoff = DEFAULT_MAX_BACKOFF
+		if rw.opt.MaxBackoff < rw.opt.MinBackoff {
+			rw.opt.MaxBackoff = rw.opt.MinBackoff
+		}
+	}
+	if rw.opt.Jitter < 0 {
+		rw.opt.Jitter = 0
+	} else if rw.opt.Jitter > 1 {
+		rw.opt.Jitter = 1
+	}
+	rw.connect()
+	return rw
+}
+
+// State returns the current connection state.
+func (rw *ReconnectingWriter) State() ConnState {
+	return rw.state
+}
+
+// ContentType returns the content type negotiated on the current
+// connection, or nil if disconnected.
+func (rw *ReconnectingWriter) ContentType() []byte {
+	if rw.w == nil {
+		return nil
+	}
+	return rw.w.ContentType()
+}
+
+// WriteFrame writes a data frame to the current connection, first
+// reconnecting if disconnected and the backoff delay has passed. If no
+// connection is available, WriteFrame returns ErrNotConnected or the error
+// from the failed connection attempt.
+func (rw *ReconnectingWriter) WriteFrame(frame []byte) (n int, err error) {
+	if err = rw.connect(); err != nil {
+		return 0, err
+	}
+	n, err = rw.w.WriteFrame(frame)
+	if err != nil {
+		rw.fail(err)
+	}
+	return
+}
+
+// Flush flushes the current connection. It does not attempt to reconnect.
+func (rw *ReconnectingWriter) Flush() (err error) {
+	if rw.state == StateClosed {
+		return ErrWriterClosed
+	}
+	if rw.w == nil {
+		return ErrNotConnected
+	}
+	if err = rw.w.Flush(); err != nil {
+		rw.fail(err)
+	}
+	return
+}
+
+// Close shuts down the current connection, if any, as with Writer.Close,
+// and stops any further reconnection.
+func (rw *ReconnectingWriter) Close() (err error) {
+	if rw.state == StateClosed {
+		return ErrWriterClosed
+	}
+	if rw.w != nil {
+		err = rw.w.Close()
+		if cerr := rw.conn.Close(); err == nil {
+			err = cerr
+		}
+		rw.conn, rw.w = nil, nil
+	}
+	rw.setState(StateClosed, nil)
+	return
+}
+
+func (rw *ReconnectingWriter) connect() error {
+	switch {
+	case rw.state == StateClosed:
+		return ErrWriterClosed
+	case rw.w != nil:
+		return nil
+	case time.Now().Before(rw.next):
+		return ErrNotConnected
+	}
+
+	conn, err := rw.dial()
+	if err != nil {
+		rw.fail(err)
+		return err
+	}
+	w, err := NewWriter(conn, &rw.opt.WriterOptions)
+	if err != nil {
+		conn.Close()
+		rw.fail(err)
+		return err
+	}
+
+	rw.conn, rw.w = conn, w
+	rw.backoff = 0
+	rw.next = time.Time{}
+	rw.setState(StateConnected, nil)
+	return nil
+}
+
+// fail drops the current connection, if any, and schedules the next
+// connection attempt.
+func (rw *ReconnectingWriter) fail(err error) {
+	if rw.conn != nil {
+		rw.conn.Close()
+		rw.conn, rw.w = nil, nil
+	}
+
+	if rw.backoff == 0 {
+		rw.backoff = rw.opt.MinBackoff
+	} else if rw.backoff *= 2; rw.backoff > rw.opt.MaxBackoff {
+		rw.backoff = rw.opt.MaxBackoff
+	}
+	delay := rw.backoff
+	if rw.opt.Jitter > 0 {
+		delay += time.Duration(rw.opt.Jitter * (2*rw.rand.Float64() - 1) * float64(delay))
+	}
+	rw.next = time.Now().Add(delay)
+
+	rw.state = StateDisconnected
+	if rw.opt.OnStateChange != nil {
+		rw.opt.OnStateChange(StateDisconnected, err)
+	}
+}
+
+func (rw *ReconnectingWriter) setState(state ConnState, err error) {
+	if rw.state == state {
+		return
+	}
+	rw.state = state
+	if rw.opt.OnStateChange != nil {
+		rw.opt.OnStateChange(state, err)
+	}
+}
@@ -0,0 +1,122 @@
+package framestream_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+// collectServer serves Frame Streams on path, sending each data frame
+// received to the returned channel.
+func collectServer(t *testing.T, path string) (*framestream.Server, chan []byte) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := make(chan []byte, 64)
+	s := &framestream.Server{
+		Handler: framestream.HandlerFunc(func(ctx context.Context, r *framestream.Reader, conn net.Conn) {
+			buf := make([]byte, 64)
+			for {
+				n, err := r.ReadFrame(buf)
+				if err != nil {
+					return
+				}
+				frames <- append([]byte(nil), buf[:n]...)
+			}
+		}),
+	}
+	go s.Serve(l)
+	return s, frames
+}
+
+func TestReconnectingWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fstrm.sock")
+	var states []framestream.ConnState
+
+	// Nothing is listening yet, so the initial connection fails.
+	rw := framestream.NewReconnectingWriter(
+		func() (net.Conn, error) { return net.Dial("unix", path) },
+		&framestream.ReconnectingWriterOptions{
+			WriterOptions: framestream.WriterOptions{Bidirectional: true},
+			MinBackoff:    5 * time.Millisecond,
+			MaxBackoff:    20 * time.Millisecond,
+			Jitter:        0.5,
+			OnStateChange: func(state framestream.ConnState, err error) {
+				if len(states) == 0 || states[len(states)-1] != state {
+					states = append(states, state)
+				}
+			},
+		})
+	if rw.State() != framestream.StateDisconnected {
+		t.Fatalf("state %v, expected %v", rw.State(), framestream.StateDisconnected)
+	}
+	if _, err := rw.WriteFrame([]byte("lost")); err == nil {
+		t.Fatal("WriteFrame succeeded with no listener")
+	}
+
+	writeUntil := func(frame []byte) {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, err := rw.WriteFrame(frame); err == nil {
+				if err = rw.Flush(); err == nil {
+					return
+				}
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("could not write %q", frame)
+	}
+
+	s, frames := collectServer(t, path)
+	writeUntil([]byte("first"))
+	if f := <-frames; string(f) != "first" {
+		t.Errorf("received %q, expected %q", f, "first")
+	}
+
+	// Restart the collector. Writes fail until the ReconnectingWriter
+	// has redialed and repeated the handshake.
+	s.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for rw.State() == framestream.StateConnected {
+		if time.Now().After(deadline) {
+			t.Fatal("failure not detected")
+		}
+		rw.WriteFrame([]byte("lost"))
+		rw.Flush()
+		time.Sleep(time.Millisecond)
+	}
+	s, frames = collectServer(t, path)
+	defer s.Close()
+	writeUntil([]byte("second"))
+	if f := <-frames; string(f) != "second" {
+		t.Errorf("received %q, expected %q", f, "second")
+	}
+
+	if err := rw.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, err := rw.WriteFrame([]byte("closed")); err != framestream.ErrWriterClosed {
+		t.Errorf("WriteFrame after Close returned %v", err)
+	}
+
+	expected := []framestream.ConnState{
+		framestream.StateDisconnected,
+		framestream.StateConnected,
+		framestream.StateDisconnected,
+		framestream.StateConnected,
+		framestream.StateClosed,
+	}
+	if len(states) != len(expected) {
+		t.Fatalf("states %v, expected %v", states, expected)
+	}
+	for i := range states {
+		if states[i] != expected[i] {
+			t.Fatalf("states %v, expected %v", states, expected)
+		}
+	}
+}
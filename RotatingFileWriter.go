@@ -0,0 +1,280 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingFileWriterOptions specifies configuration for a
+// RotatingFileWriter.
+type RotatingFileWriterOptions struct {
+	// Pattern gives the name of each file, expanded with Strftime at the
+	// time the file is opened. If the name is already taken, a numeric
+	// suffix is added.
+	Pattern string
+	// ContentType is written in the START frame of each file. May be left
+	// unset for no content type.
+	ContentType []byte
+	// MaxBytes, if nonzero, starts a new file before a data frame would
+	// take the current file's data frames, including their length
+	// prefixes, over MaxBytes bytes.
+	MaxBytes int64
+	// MaxFrames, if nonzero, starts a new file after every MaxFrames
+	// data frames.
+	MaxFrames int64
+	// Interval, if nonzero, starts a new file with the first frame
+	// written after each multiple of Interval, counted from midnight in
+	// the time zone in which Pattern is expanded.
+	Interval time.Duration
+	// UTC expands Pattern in UTC rather than local time.
+	UTC bool
+	// PostRotate, if set, is called in a new goroutine with the name of
+	// each file after it is completed and closed.
+	PostRotate func(path string)
+}
+
+// A RotatingFileWriter writes data frames to a series of Frame Streams
+// files, starting a new file when the current one reaches a size, frame
+// count or age limit. Each file is a complete stream beginning with a START
+// frame and ending with a STOP frame.
+type RotatingFileWriter struct {
+	opt      RotatingFileWriterOptions
+	file     *os.File
+	w        *Writer
+	path     string
+	bytes    int64
+	frames   int64
+	deadline time.Time
+	closed   bool
+	hooks    sync.WaitGroup
+	// err is the error which left no file open, if w is nil.
+	err error
+}
+
+// NewRotatingFileWriter returns a RotatingFileWriter with the given options,
+// having opened its first file.
+func NewRotatingFileWriter(opt *RotatingFileWriterOptions) (*RotatingFileWriter, error) {
+	if opt == nil || opt.Pattern == "" {
+		return nil, ErrNoPattern
+	}
+	rw := &RotatingFileWriter{opt: *opt}
+	if err := rw.open(); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+// Path returns the name of the file currently being written.
+func (rw *RotatingFileWriter) Path() string {
+	return rw.path
+}
+
+// WriteFrame writes a data frame to the current file, first moving to a
+// new file if the frame would exceed the limits of the current one.
+func (rw *RotatingFileWriter) WriteFrame(frame []byte) (n int, err error) {
+	if rw.closed {
+		return 0, ErrWriterClosed
+	}
+	if rw.w == nil {
+		return 0, rw.err
+	}
+	size := int64(len(frame)) + 4
+	if rw.needRotate(size) {
+		if err = rw.Rotate(); err != nil {
+			return
+		}
+	}
+	n, err = rw.w.WriteFrame(frame)
+	if err != nil {
+		return
+	}
+	rw.bytes += size
+	rw.frames++
+	return
+}
+
+// Flush writes any buffered data frames to the current file.
+func (rw *RotatingFileWriter) Flush() error {
+	if rw.closed {
+		return ErrWriterClosed
+	}
+	if rw.w == nil {
+		return rw.err
+	}
+	return rw.w.Flush()
+}
+
+// Rotate completes the current file and starts a new one. If either
+// fails, writes fail with the error until Rotate succeeds.
+func (rw *RotatingFileWriter) Rotate() error {
+	if rw.closed {
+		return ErrWriterClosed
+	}
+	if rw.w != nil {
+		if err := rw.finish(); err != nil {
+			rw.err = err
+			return err
+		}
+	}
+	return rw.open()
+}
+
+// Close completes the current file and waits for any PostRotate hooks
+// to return.
+func (rw *RotatingFileWriter) Close() error {
+	if rw.closed {
+		return ErrWriterClosed
+	}
+	rw.closed = true
+	var err error
+	if rw.w != nil {
+		err = rw.finish()
+	}
+	rw.hooks.Wait()
+	return err
+}
+
+func (rw *RotatingFileWriter) needRotate(size int64) bool {
+	if rw.frames == 0 {
+		return false
+	}
+	if rw.opt.MaxBytes > 0 && rw.bytes+size > rw.opt.MaxBytes {
+		return true
+	}
+	if rw.opt.MaxFrames > 0 && rw.frames >= rw.opt.MaxFrames {
+		return true
+	}
+	if rw.opt.Interval > 0 && !time.Now().Before(rw.deadline) {
+		return true
+	}
+	return false
+}
+
+func (rw *RotatingFileWriter) open() error {
+	now := time.Now()
+	if rw.opt.UTC {
+		now = now.UTC()
+	}
+	base := Strftime(rw.opt.Pattern, now)
+
+	var file *os.File
+	var err error
+	path := base
+	for i := 1; ; i++ {
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !os.IsExist(err) {
+			break
+		}
+		path = base + "." + strconv.Itoa(i)
+	}
+	if err != nil {
+		rw.err = err
+		return err
+	}
+
+	w, err := NewWriter(file, &WriterOptions{ContentTypes: [][]byte{rw.opt.ContentType}})
+	if err != nil {
+		file.Close()
+		rw.err = err
+		return err
+	}
+
+	rw.file, rw.w, rw.path = file, w, path
+	rw.bytes, rw.frames = 0, 0
+	if rw.opt.Interval > 0 {
+		rw.deadline = nextBoundary(now, rw.opt.Interval)
+	}
+	return nil
+}
+
+// nextBoundary returns the first multiple of interval after t, counted
+// from midnight in t's location rather than in UTC.
+func nextBoundary(t time.Time, interval time.Duration) time.Time {
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(interval).Add(interval - shift)
+}
+
+// finish completes and closes the current file, leaving none open.
+func (rw *RotatingFileWriter) finish() error {
+	err := rw.w.Close()
+	if cerr := rw.file.Close(); err == nil {
+		err = cerr
+	}
+	rw.w, rw.file = nil, nil
+	if err != nil {
+		return fmt.Errorf("%s: %w", rw.path, err)
+	}
+	if rw.opt.PostRotate != nil {
+		rw.hooks.Add(1)
+		go func(path string) {
+			defer rw.hooks.Done()
+			rw.opt.PostRotate(path)
+		}(rw.path)
+	}
+	return nil
+}
+
+// Strftime expands the conversion specifications %Y, %y, %m, %d, %j, %H,
+// %M, %S, %s, %F, %T and %% in pattern with values from t. Other
+// characters, including unrecognized specifications, are copied unchanged.
+func Strftime(pattern string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 's':
+			fmt.Fprintf(&b, "%d", t.Unix())
+		case 'F':
+			b.WriteString(t.Format("2006-01-02"))
+		case 'T':
+			b.WriteString(t.Format("15:04:05"))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(pattern[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,186 @@
+package framestream_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+// readFile returns the content type and data frames of a capture file.
+func readFile(t *testing.T, path string) (string, []string) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := framestream.NewReader(f, &framestream.ReaderOptions{
+		ContentTypes: contentTypes("test"),
+	})
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	var frames []string
+	buf := make([]byte, 64)
+	for {
+		n, err := r.ReadFrame(buf)
+		if err == framestream.EOF {
+			return string(r.ContentType()), frames
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		frames = append(frames, string(buf[:n]))
+	}
+}
+
+func testRotatingFileWriter(t *testing.T, opt framestream.RotatingFileWriterOptions, frames []string, pause time.Duration, expected [][]string) {
+	dir := t.TempDir()
+	opt.Pattern = filepath.Join(dir, "capture.fstrm")
+	opt.ContentType = []byte("test")
+	var mu sync.Mutex
+	var rotated []string
+	opt.PostRotate = func(path string) {
+		mu.Lock()
+		rotated = append(rotated, path)
+		mu.Unlock()
+	}
+
+	rw, err := framestream.NewRotatingFileWriter(&opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range frames {
+		if _, err := rw.WriteFrame([]byte(f)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(pause)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, _ := filepath.Glob(opt.Pattern + "*")
+	// Files are named capture.fstrm, capture.fstrm.1, capture.fstrm.2, ...
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return paths[i] < paths[j]
+	})
+	if len(paths) != len(expected) {
+		t.Fatalf("wrote %d files %v, expected %d", len(paths), paths, len(expected))
+	}
+	if len(rotated) != len(paths) {
+		t.Errorf("PostRotate called for %v, expected %v", rotated, paths)
+	}
+	for i, path := range paths {
+		ctype, got := readFile(t, path)
+		if ctype != "test" {
+			t.Errorf("%s: content type %q", path, ctype)
+		}
+		if len(got) != len(expected[i]) {
+			t.Fatalf("%s: frames %v, expected %v", path, got, expected[i])
+		}
+		for j := range got {
+			if got[j] != expected[i][j] {
+				t.Fatalf("%s: frames %v, expected %v", path, got, expected[i])
+			}
+		}
+	}
+}
+
+func TestRotatingFileWriterFrames(t *testing.T) {
+	testRotatingFileWriter(t,
+		framestream.RotatingFileWriterOptions{MaxFrames: 3},
+		[]string{"a", "b", "c", "d", "e", "f", "g"}, 0,
+		[][]string{{"a", "b", "c"}, {"d", "e", "f"}, {"g"}})
+}
+
+func TestRotatingFileWriterBytes(t *testing.T) {
+	// Each frame takes 4 + 4 bytes.
+	testRotatingFileWriter(t,
+		framestream.RotatingFileWriterOptions{MaxBytes: 20},
+		[]string{"aaaa", "bbbb", "cccc", "dddd", "eeee"}, 0,
+		[][]string{{"aaaa", "bbbb"}, {"cccc", "dddd"}, {"eeee"}})
+}
+
+func TestRotatingFileWriterInterval(t *testing.T) {
+	testRotatingFileWriter(t,
+		framestream.RotatingFileWriterOptions{Interval: 100 * time.Millisecond},
+		[]string{"a", "b"}, 150*time.Millisecond,
+		[][]string{{"a"}, {"b"}})
+}
+
+func TestRotatingFileWriterLocalBoundary(t *testing.T) {
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	now := time.Date(2014, time.March, 7, 23, 30, 0, 0, zone)
+	expected := time.Date(2014, time.March, 8, 0, 0, 0, 0, zone)
+	if next := framestream.NextBoundary(now, 24*time.Hour); !next.Equal(expected) {
+		t.Errorf("next daily boundary after %v is %v, expected %v", now, next, expected)
+	}
+	expected = time.Date(2014, time.March, 7, 23, 45, 0, 0, zone)
+	if next := framestream.NextBoundary(now, 15*time.Minute); !next.Equal(expected) {
+		t.Errorf("next boundary after %v is %v, expected %v", now, next, expected)
+	}
+}
+
+func TestRotatingFileWriterRotateFailed(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	rw, err := framestream.NewRotatingFileWriter(&framestream.RotatingFileWriterOptions{
+		Pattern: filepath.Join(dir, "capture.fstrm"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rw.WriteFrame([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next file cannot be opened.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	rerr := rw.Rotate()
+	if rerr == nil {
+		t.Fatal("Rotate succeeded without its directory")
+	}
+	if _, err := rw.WriteFrame([]byte("b")); err != rerr {
+		t.Errorf("WriteFrame after failed Rotate: %v, expected %v", err, rerr)
+	}
+	if err := rw.Flush(); err != rerr {
+		t.Errorf("Flush after failed Rotate: %v, expected %v", err, rerr)
+	}
+
+	// Rotate recovers once the directory is back.
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := rw.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rw.WriteFrame([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, frames := readFile(t, rw.Path()); len(frames) != 1 || frames[0] != "c" {
+		t.Errorf("frames %q after recovery", frames)
+	}
+}
+
+func TestStrftime(t *testing.T) {
+	tm := time.Date(2014, time.March, 7, 9, 5, 3, 0, time.UTC)
+	s := framestream.Strftime("dnstap.%Y%m%d.%H%M%S.%j.%%.%q", tm)
+	if expected := "dnstap.20140307.090503.066.%.%q"; s != expected {
+		t.Errorf("Strftime returned %q, expected %q", s, expected)
+	}
+}
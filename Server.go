@@ -0,0 +1,240 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// A Handler processes the data frames of a single Frame Streams connection
+// accepted by a Server.
+//
+// ServeFrames is called after the handshake has completed. The connection
+// is closed when ServeFrames returns. The supplied context is cancelled
+// when the Server is shut down or closed, so that handlers waiting on
+// anything but the connection can finish their streams.
+type Handler interface {
+	ServeFrames(ctx context.Context, r *Reader, conn net.Conn)
+}
+
+// HandlerFunc adapts an ordinary function to the Handler interface.
+type HandlerFunc func(ctx context.Context, r *Reader, conn net.Conn)
+
+// ServeFrames calls f(ctx, r, conn).
+func (f HandlerFunc) ServeFrames(ctx context.Context, r *Reader, conn net.Conn) {
+	f(ctx, r, conn)
+}
+
+// A Server accepts Frame Streams connections from one or more net.Listeners
+// and passes a Reader for each to its Handler.
+type Server struct {
+	// Handler is called for each connection which completes the
+	// handshake. It must be set.
+	Handler Handler
+	// ReaderOptions gives the options used to create the Reader for each
+	// connection. If nil, connections are read with Bidirectional set
+	// and no content type negotiation.
+	ReaderOptions *ReaderOptions
+	// ConnReaderOptions, if set, is called for each accepted connection
+	// and overrides ReaderOptions with its result.
+	ConnReaderOptions func(conn net.Conn) *ReaderOptions
+	// ErrorLog receives handshake failures, accept errors and handler
+	// panics. If nil, the log package's standard logger is used.
+	ErrorLog *log.Logger
+
+	mu         sync.Mutex
+	listeners  map[net.Listener]struct{}
+	conns      map[net.Conn]struct{}
+	inShutdown bool
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// Serve accepts connections on l, performing the Frame Streams handshake
+// and calling the Server's Handler for each in a new goroutine. Serve
+// always returns a non-nil error, which is ErrServerClosed after Shutdown
+// or Close.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.trackListener(l, true) {
+		return ErrServerClosed
+	}
+	defer s.trackListener(l, false)
+
+	var delay time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				s.logf("framestream: accept error: %v; retrying in %v", err, delay)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+		if !s.trackConn(conn, true) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Shutdown stops the Server from accepting new connections, cancels the
+// context passed to its Handler, and waits for connections in progress to
+// finish their streams. If ctx expires first, Shutdown closes the remaining
+// connections and returns ctx.Err() without waiting for their handlers to
+// return.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.init()
+	s.inShutdown = true
+	s.cancel()
+	for l := range s.listeners {
+		l.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Close()
+		return ctx.Err()
+	}
+}
+
+// Close immediately closes all listeners and connections of the Server
+// and cancels the context passed to its Handler.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.init()
+	s.inShutdown = true
+	s.cancel()
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.trackConn(conn, false)
+	defer conn.Close()
+	defer func() {
+		if p := recover(); p != nil {
+			s.logf("framestream: panic serving %v: %v\n%s",
+				conn.RemoteAddr(), p, debug.Stack())
+		}
+	}()
+
+	opt := s.ReaderOptions
+	if s.ConnReaderOptions != nil {
+		opt = s.ConnReaderOptions(conn)
+	}
+	if opt == nil {
+		opt = &ReaderOptions{Bidirectional: true}
+	}
+
+	r, err := NewReader(conn, opt)
+	if err != nil {
+		s.logf("framestream: handshake with %v failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	s.Handler.ServeFrames(s.ctx, r, conn)
+}
+
+// init must be called with s.mu held.
+func (s *Server) init() {
+	if s.ctx == nil {
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+		s.listeners = make(map[net.Listener]struct{})
+		s.conns = make(map[net.Conn]struct{})
+	}
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inShutdown
+}
+
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	if !add {
+		delete(s.listeners, l)
+		return true
+	}
+	if s.inShutdown {
+		return false
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+func (s *Server) trackConn(c net.Conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.init()
+	if !add {
+		delete(s.conns, c)
+		return true
+	}
+	if s.inShutdown {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,199 @@
+package framestream_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+func startServer(t *testing.T, s *framestream.Server) (net.Listener, chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(l)
+	}()
+	return l, served
+}
+
+func TestServer(t *testing.T) {
+	frames := make(chan []byte, 16)
+	s := &framestream.Server{
+		ReaderOptions: &framestream.ReaderOptions{
+			Bidirectional: true,
+			ContentTypes:  contentTypes("test"),
+		},
+		Handler: framestream.HandlerFunc(func(ctx context.Context, r *framestream.Reader, conn net.Conn) {
+			buf := make([]byte, 64)
+			for {
+				n, err := r.ReadFrame(buf)
+				if err != nil {
+					close(frames)
+					return
+				}
+				frames <- append([]byte(nil), buf[:n]...)
+			}
+		}),
+	}
+	l, served := startServer(t, s)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	w, err := framestream.NewWriter(conn, &framestream.WriterOptions{
+		Bidirectional: true,
+		ContentTypes:  contentTypes("test"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 10; i++ {
+		if _, err := w.WriteFrame(make([]byte, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	i := 1
+	for f := range frames {
+		if bytes.Compare(f, make([]byte, i)) != 0 {
+			t.Errorf("frame %d: received %v", i, f)
+		}
+		i++
+	}
+	if i != 10 {
+		t.Errorf("received %d frames, expected 9", i-1)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if err := <-served; err != framestream.ErrServerClosed {
+		t.Errorf("Serve returned %v, expected %v", err, framestream.ErrServerClosed)
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	s := &framestream.Server{
+		Handler: framestream.HandlerFunc(func(ctx context.Context, r *framestream.Reader, conn net.Conn) {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			// The peer never ends its stream, so this returns only
+			// when the connection is closed.
+			r.Next()
+		}),
+	}
+	l, served := startServer(t, s)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := framestream.NewWriter(conn, &framestream.WriterOptions{Bidirectional: true}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(ctx) }()
+
+	// The handler context is cancelled as soon as Shutdown begins.
+	select {
+	case <-cancelled:
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v before cancelling the handler context", err)
+	}
+	if err := <-shutdown; err != context.DeadlineExceeded {
+		t.Errorf("Shutdown returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if err := <-served; err != framestream.ErrServerClosed {
+		t.Errorf("Serve returned %v, expected %v", err, framestream.ErrServerClosed)
+	}
+
+	t.Run("stuck handler", testServerShutdownStuck)
+}
+
+// testServerShutdownStuck checks that Shutdown returns when ctx expires,
+// even though a handler does not.
+func testServerShutdownStuck(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	s := &framestream.Server{
+		Handler: framestream.HandlerFunc(func(ctx context.Context, r *framestream.Reader, conn net.Conn) {
+			close(started)
+			// Ignores ctx and the connection.
+			<-release
+		}),
+	}
+	l, _ := startServer(t, s)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := framestream.NewWriter(conn, &framestream.WriterOptions{Bidirectional: true}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(ctx) }()
+	select {
+	case err := <-shutdown:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Shutdown returned %v, expected %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown waited for a handler ignoring its context")
+	}
+}
+
+func TestServerHandlerPanic(t *testing.T) {
+	s := &framestream.Server{
+		ErrorLog: log.New(ioutil.Discard, "", 0),
+		Handler: framestream.HandlerFunc(func(ctx context.Context, r *framestream.Reader, conn net.Conn) {
+			panic("test panic")
+		}),
+	}
+	l, _ := startServer(t, s)
+	defer s.Close()
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := framestream.NewWriter(conn, &framestream.WriterOptions{Bidirectional: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The server closes the connection after the handler panics,
+		// so the STOP/FINISH exchange fails.
+		if err := w.Close(); err == nil {
+			t.Errorf("connection %d: Close succeeded after handler panic", i)
+		}
+		conn.Close()
+	}
+}
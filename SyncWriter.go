@@ -0,0 +1,216 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"encoding/binary"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+const DEFAULT_SHARD_SIZE = 64 * 1024
+
+// SyncWriterOptions specifies configuration for a SyncWriter.
+type SyncWriterOptions struct {
+	// Shards is the number of buffers shared among the SyncWriter's
+	// Producers. It defaults to runtime.GOMAXPROCS(0).
+	Shards int
+	// ShardSize is the number of bytes a shard buffers before they are
+	// written to the underlying Writer. It defaults to DEFAULT_SHARD_SIZE.
+	ShardSize int
+}
+
+// A SyncWriter makes a Writer safe for concurrent use. Each frame is
+// written atomically, and the frames written by a goroutine through
+// WriteFrame, or through a Producer, are written in order.
+//
+// WriteFrame holds a lock on the Writer for each frame. Producers instead
+// buffer frames in one of several shards, taking the lock on the Writer
+// only to write out a full shard, and so scale better with many
+// goroutines writing.
+//
+// The Writer's Metrics, if any, must be safe for concurrent use.
+type SyncWriter struct {
+	opt    SyncWriterOptions
+	shards []*syncShard
+	next   uint32
+
+	mu     sync.Mutex
+	w      *Writer
+	closed bool
+}
+
+// A syncShard holds complete frames, with their length prefixes, for
+// writing to the underlying Writer.
+type syncShard struct {
+	mu     sync.Mutex
+	buf    []byte
+	frames int
+	closed bool
+}
+
+// NewSyncWriter returns a SyncWriter writing to w. The SyncWriter takes
+// ownership of w; it must not be used directly afterward.
+func NewSyncWriter(w *Writer, opt *SyncWriterOptions) *SyncWriter {
+	if opt == nil {
+		opt = &SyncWriterOptions{}
+	}
+	sw := &SyncWriter{w: w, opt: *opt}
+	if sw.opt.Shards <= 0 {
+		sw.opt.Shards = runtime.GOMAXPROCS(0)
+	}
+	if sw.opt.ShardSize <= 0 {
+		sw.opt.ShardSize = DEFAULT_SHARD_SIZE
+	}
+	sw.shards = make([]*syncShard, sw.opt.Shards)
+	for i := range sw.shards {
+		sw.shards[i] = &syncShard{buf: make([]byte, 0, sw.opt.ShardSize)}
+	}
+	return sw
+}
+
+// WriteFrame writes frame to the underlying Writer, holding its lock.
+func (sw *SyncWriter) WriteFrame(frame []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.closed {
+		return 0, ErrWriterClosed
+	}
+	return sw.w.WriteFrame(frame)
+}
+
+// Flush writes the frames buffered by all Producers to the underlying
+// Writer, and flushes it.
+func (sw *SyncWriter) Flush() error {
+	for _, s := range sw.shards {
+		s.mu.Lock()
+		err := sw.drain(s)
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.closed {
+		return ErrWriterClosed
+	}
+	return sw.w.Flush()
+}
+
+// Close writes the frames buffered by all Producers, then closes the
+// underlying Writer. Frames written afterward fail with ErrWriterClosed.
+func (sw *SyncWriter) Close() error {
+	var err error
+	for _, s := range sw.shards {
+		s.mu.Lock()
+		if e := sw.drain(s); err == nil {
+			err = e
+		}
+		s.closed = true
+		s.mu.Unlock()
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.closed {
+		return ErrWriterClosed
+	}
+	sw.closed = true
+	if e := sw.w.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// drain writes the frames buffered in s to the underlying Writer. The
+// caller must hold s.mu.
+func (sw *SyncWriter) drain(s *syncShard) error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.closed {
+		return ErrWriterClosed
+	}
+	err := sw.w.writeFramed(s.buf, s.frames)
+	s.buf = s.buf[:0]
+	s.frames = 0
+	return err
+}
+
+// Producer returns a Producer buffering frames in one of the SyncWriter's
+// shards, assigned in turn. A Producer should be used by one goroutine
+// at a time.
+func (sw *SyncWriter) Producer() *Producer {
+	n := atomic.AddUint32(&sw.next, 1)
+	return &Producer{sw: sw, s: sw.shards[int(n-1)%len(sw.shards)]}
+}
+
+// A Producer writes frames to a SyncWriter through one of its shards. The
+// frames written by a Producer are written to the underlying Writer in
+// order, but only once its shard is full or the SyncWriter or Producer is
+// flushed. The Writer's FlushPolicy applies as each shard is written.
+type Producer struct {
+	sw *SyncWriter
+	s  *syncShard
+}
+
+// WriteFrame buffers frame in the Producer's shard, first writing out the
+// shard if the frame does not fit. Frames longer than the shard are
+// written directly to the underlying Writer.
+func (p *Producer) WriteFrame(frame []byte) (int, error) {
+	s := p.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, ErrWriterClosed
+	}
+	if len(s.buf)+4+len(frame) > cap(s.buf) {
+		if err := p.sw.drain(s); err != nil {
+			return 0, err
+		}
+		if 4+len(frame) > cap(s.buf) {
+			return p.sw.WriteFrame(frame)
+		}
+	}
+	s.buf = binary.BigEndian.AppendUint32(s.buf, uint32(len(frame)))
+	s.buf = append(s.buf, frame...)
+	s.frames++
+	if m := p.sw.w.opt.Metrics; m != nil {
+		m.DataFrame(len(frame))
+	}
+	return len(frame), nil
+}
+
+// Flush writes the frames buffered in the Producer's shard to the
+// underlying Writer, and flushes it.
+func (p *Producer) Flush() error {
+	p.s.mu.Lock()
+	err := p.sw.drain(p.s)
+	p.s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	p.sw.mu.Lock()
+	defer p.sw.mu.Unlock()
+	if p.sw.closed {
+		return ErrWriterClosed
+	}
+	return p.sw.w.Flush()
+}
@@ -0,0 +1,159 @@
+package framestream_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+// syncFrame returns frame seq of producer id, of a length varying with seq.
+func syncFrame(id, seq int) []byte {
+	frame := make([]byte, 8+seq%97)
+	binary.BigEndian.PutUint32(frame, uint32(id))
+	binary.BigEndian.PutUint32(frame[4:], uint32(seq))
+	for i := 8; i < len(frame); i++ {
+		frame[i] = byte(id + seq + i)
+	}
+	return frame
+}
+
+func TestSyncWriter(t *testing.T) {
+	const producers, frames = 32, 500
+
+	buf := new(bytes.Buffer)
+	w, err := framestream.NewWriter(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw := framestream.NewSyncWriter(w, &framestream.SyncWriterOptions{
+		Shards:    4,
+		ShardSize: 1024,
+	})
+
+	var wg sync.WaitGroup
+	for id := 0; id < producers; id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			// Half of the goroutines write through their own Producer.
+			write := sw.WriteFrame
+			if id%2 == 0 {
+				write = sw.Producer().WriteFrame
+			}
+			for seq := 0; seq < frames; seq++ {
+				if _, err := write(syncFrame(id, seq)); err != nil {
+					t.Error(err)
+					return
+				}
+				if seq%100 == 0 {
+					if err := sw.Flush(); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(id)
+	}
+	// A large frame, written directly by a Producer.
+	if _, err := sw.Producer().WriteFrame(make([]byte, 4096)); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := framestream.NewReader(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := make([]int, producers)
+	large := 0
+	for {
+		frame, err := r.Next()
+		if err == framestream.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frame) == 4096 {
+			large++
+			continue
+		}
+		id := int(binary.BigEndian.Uint32(frame))
+		seq := int(binary.BigEndian.Uint32(frame[4:]))
+		if id >= producers || seq != next[id] {
+			t.Fatalf("producer %d frame %d, expected %d", id, seq, next[id])
+		}
+		if !bytes.Equal(frame, syncFrame(id, seq)) {
+			t.Fatalf("producer %d frame %d corrupt", id, seq)
+		}
+		next[id]++
+	}
+	for id, n := range next {
+		if n != frames {
+			t.Errorf("producer %d: read %d frames, expected %d", id, n, frames)
+		}
+	}
+	if large != 1 {
+		t.Errorf("read %d large frames", large)
+	}
+}
+
+func TestSyncWriterClosed(t *testing.T) {
+	w, err := framestream.NewWriter(io.Discard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw := framestream.NewSyncWriter(w, nil)
+	p := sw.Producer()
+	if _, err := p.WriteFrame([]byte("frame")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, write := range []func([]byte) (int, error){sw.WriteFrame, p.WriteFrame} {
+		if _, err := write([]byte("frame")); !errors.Is(err, framestream.ErrWriterClosed) {
+			t.Errorf("write %d after Close: %v", i, err)
+		}
+	}
+	if err := sw.Close(); !errors.Is(err, framestream.ErrWriterClosed) {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func benchmarkSyncWriter(b *testing.B, producer bool) {
+	w, err := framestream.NewWriter(io.Discard, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sw := framestream.NewSyncWriter(w, nil)
+	frame := make([]byte, 256)
+	b.SetBytes(int64(len(frame)))
+	b.RunParallel(func(pb *testing.PB) {
+		write := sw.WriteFrame
+		if producer {
+			write = sw.Producer().WriteFrame
+		}
+		for pb.Next() {
+			write(frame)
+		}
+	})
+	sw.Close()
+}
+
+func BenchmarkSyncWriter(b *testing.B) {
+	for _, producer := range []bool{false, true} {
+		b.Run(fmt.Sprintf("producer=%v", producer), func(b *testing.B) {
+			benchmarkSyncWriter(b, producer)
+		})
+	}
+}
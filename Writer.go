@@ -18,11 +18,33 @@ package framestream
 
 import (
 	"bufio"
+	"context"
 	"encoding/binary"
 	"io"
+	"log/slog"
+	"net"
+	"sync"
 	"time"
 )
 
+// A FlushPolicy determines when a Writer flushes buffered data frames
+// without a call to Flush. Its zero value flushes only when the buffer is
+// full. A Writer flushes when any of the conditions set is met.
+type FlushPolicy struct {
+	// If EveryFrame is true, each data frame is flushed as it is written.
+	EveryFrame bool
+	// If Frames is nonzero, buffered frames are flushed once Frames
+	// frames have been written since the last flush.
+	Frames int
+	// If Bytes is nonzero, buffered frames are flushed once at least
+	// Bytes bytes are buffered.
+	Bytes int
+	// If Latency is nonzero, a background timer flushes frames which
+	// have been buffered for Latency. An error flushing is returned by
+	// the next call writing a data frame.
+	Latency time.Duration
+}
+
 type WriterOptions struct {
 	// The ContentTypes available to be written to the Writer. May be
 	// left unset for no content negotiation. If the Reader requests a
@@ -36,8 +58,25 @@ type WriterOptions struct {
 	Bidirectional bool
 	// Timeout gives the timeout for writing both control and data frames,
 	// and for reading responses to control frames sent. It is only
-	//  effective for underlying Writers satisfying net.Conn.
+	// effective for underlying Writers supporting deadlines, such as
+	// net.Conn.
 	Timeout time.Duration
+	// Metrics, if set, receives measurements of the frames written.
+	Metrics Metrics
+	// Logger, if set, receives a debug level record of each control frame
+	// sent and received, and warn level records of failed handshakes and
+	// shutdowns.
+	Logger *slog.Logger
+	// OnControlFrame, if set, is called with each control frame sent and
+	// received. Frames are passed to it before they are sent. If it
+	// returns an error, the frame is rejected and the handshake or Close
+	// fails with the error, wrapped in a *ProtocolError if the frame was
+	// received. The ControlFrame is only valid for the duration of the
+	// call.
+	OnControlFrame func(dir Direction, cf *ControlFrame) error
+	// FlushPolicy determines when data frames are flushed without a call
+	// to Flush.
+	FlushPolicy FlushPolicy
 }
 
 // A Writer writes data frames to a Frame Streams file or connection.
@@ -46,18 +85,58 @@ type Writer struct {
 	w           *bufio.Writer
 	r           *bufio.Reader
 	opt         WriterOptions
+	tc          *timeoutConn
+	conn        net.Conn
 	buf         []byte
+	iov         [][]byte
+	pos         *streamPos
+	log         *slog.Logger
+
+	// pending counts the frames written since the last flush. mu guards
+	// the Writer against the flush timer of a FlushPolicy with a Latency.
+	pending  int
+	mu       sync.Mutex
+	timer    *time.Timer
+	armed    bool
+	flushErr error
 }
 
 // NewWriter returns a Frame Streams Writer using the given io.Writer and options.
+// Handshake errors caused by malformed or unexpected frames are returned as
+// *ProtocolError.
 func NewWriter(w io.Writer, opt *WriterOptions) (writer *Writer, err error) {
+	return NewWriterContext(context.Background(), w, opt)
+}
+
+// NewWriterContext is like NewWriter, but abandons the handshake and
+// returns ctx.Err() if ctx is done first. Blocked I/O is only interrupted
+// for underlying Writers supporting deadlines, such as net.Conn.
+func NewWriterContext(ctx context.Context, w io.Writer, opt *WriterOptions) (writer *Writer, err error) {
 	if opt == nil {
 		opt = &WriterOptions{}
 	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	began := time.Now()
+	conn, _ := w.(net.Conn)
 	w = timeoutWriter(w, opt)
 	writer = &Writer{
-		w:   bufio.NewWriter(w),
-		opt: *opt,
+		w:    bufio.NewWriter(w),
+		opt:  *opt,
+		conn: conn,
+		buf:  make([]byte, 4),
+		log:  newLogger(opt.Logger, "writer"),
+	}
+	log := writer.log
+	defer func() {
+		if err != nil {
+			logWarn(log, "handshake failed", err)
+		}
+	}()
+	if tc, ok := w.(*timeoutConn); ok {
+		writer.tc = tc
+		defer tc.bind(ctx)()
 	}
 
 	if len(opt.ContentTypes) > 0 {
@@ -69,36 +148,54 @@ func NewWriter(w io.Writer, opt *WriterOptions) (writer *Writer, err error) {
 		if !ok {
 			return nil, ErrType
 		}
-		writer.r = bufio.NewReader(r)
+		writer.pos = newStreamPos(r)
+		writer.r = bufio.NewReader(writer.pos)
 		ready := ControlReady
 		ready.SetContentTypes(opt.ContentTypes)
+		if err = writer.control(&ready, DirectionSent, PhaseHandshake); err != nil {
+			return nil, err
+		}
 		if err = ready.EncodeFlush(writer.w); err != nil {
-			return
+			return nil, contextErr(ctx, err)
 		}
 
 		var accept ControlFrame
-		if err = accept.DecodeTypeEscape(writer.r, CONTROL_ACCEPT); err != nil {
-			return
+		err = writer.pos.readControl(writer.r, &accept, CONTROL_ACCEPT, PhaseHandshake)
+		if err != nil {
+			return nil, contextErr(ctx, err)
+		}
+		if err = writer.control(&accept, DirectionReceived, PhaseHandshake); err != nil {
+			return nil, err
 		}
 
 		if t, ok := accept.ChooseContentType(opt.ContentTypes); ok {
 			writer.contentType = t
 		} else {
-			return nil, ErrContentTypeMismatch
+			return nil, writer.pos.newError(ErrContentTypeMismatch, PhaseHandshake)
 		}
 	}
 
 	// Write the start control frame.
 	start := ControlStart
 	start.SetContentType(writer.contentType)
+	if err = writer.control(&start, DirectionSent, PhaseHandshake); err != nil {
+		return nil, err
+	}
 	err = start.EncodeFlush(writer.w)
 	if err != nil {
-		return
+		return nil, contextErr(ctx, err)
+	}
+	if opt.Metrics != nil {
+		opt.Metrics.Handshake(time.Since(began))
 	}
 
 	return
 }
 
+func (w *Writer) control(cf *ControlFrame, dir Direction, phase Phase) error {
+	return observeControl(w.opt.OnControlFrame, w.opt.Metrics, w.log, w.pos, cf, dir, phase)
+}
+
 // ContentType returns the content type negotiated with Reader.
 func (w *Writer) ContentType() []byte {
 	return w.contentType
@@ -107,28 +204,330 @@ func (w *Writer) ContentType() []byte {
 // Close shuts down the Frame Streams stream by writing a CONTROL_STOP message.
 // If the Writer is Bidirectional, Close will wait for an acknowledgement
 // (CONTROL_FINISH) from its peer.
-func (w *Writer) Close() (err error) {
-	err = ControlStop.EncodeFlush(w.w)
-	if err != nil || !w.opt.Bidirectional {
+func (w *Writer) Close() error {
+	w.lock()
+	defer w.unlock()
+	return w.close()
+}
+
+func (w *Writer) close() (err error) {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.armed = false
+	}
+	w.pending = 0
+	stop := ControlStop
+	if err = w.control(&stop, DirectionSent, PhaseData); err != nil {
+		logWarn(w.log, "shutdown failed", err)
+		return
+	}
+	err = stop.EncodeFlush(w.w)
+	if err != nil {
+		logWarn(w.log, "shutdown failed", err)
+		return
+	}
+	if !w.opt.Bidirectional {
 		return
 	}
 
 	var finish ControlFrame
-	return finish.DecodeTypeEscape(w.r, CONTROL_FINISH)
+	err = w.pos.readControl(w.r, &finish, CONTROL_FINISH, PhaseShutdown)
+	if err != nil {
+		logWarn(w.log, "shutdown failed", err)
+		return
+	}
+	if err = w.control(&finish, DirectionReceived, PhaseShutdown); err != nil {
+		logWarn(w.log, "shutdown failed", err)
+	}
+	return
+}
+
+// CloseContext is like Close, but returns ctx.Err() if ctx is done before
+// the stream is shut down. Blocked I/O is only interrupted for underlying
+// Writers supporting deadlines, such as net.Conn. If a write is interrupted,
+// the Writer keeps the error and cannot be used further.
+func (w *Writer) CloseContext(ctx context.Context) error {
+	return w.withContext(ctx, w.close)
 }
 
 // WriteFrame writes the given frame to the underlying io.Writer with Frame Streams
 // framing.
-func (w *Writer) WriteFrame(frame []byte) (n int, err error) {
-	err = binary.Write(w.w, binary.BigEndian, uint32(len(frame)))
+func (w *Writer) WriteFrame(frame []byte) (int, error) {
+	w.lock()
+	defer w.unlock()
+	return w.writeFrame(frame)
+}
+
+func (w *Writer) writeFrame(frame []byte) (n int, err error) {
+	if err = w.timerErr(); err != nil {
+		return
+	}
+	binary.BigEndian.PutUint32(w.buf[:4], uint32(len(frame)))
+	_, err = w.w.Write(w.buf[:4])
 	if err != nil {
 		return
 	}
-	return w.w.Write(frame)
+	n, err = w.w.Write(frame)
+	if err != nil {
+		return
+	}
+	if w.opt.Metrics != nil {
+		w.opt.Metrics.DataFrame(n)
+	}
+	err = w.wrote(1)
+	return
+}
+
+// WriteFrames writes each of the given frames as with WriteFrame, returning
+// their total length. If the frames are large, do not fit in the Writer's
+// buffer, and the underlying io.Writer is a net.Conn, the buffer is flushed
+// and the frames written with a single vectored write where supported.
+func (w *Writer) WriteFrames(frames [][]byte) (n int, err error) {
+	w.lock()
+	defer w.unlock()
+	if err = w.timerErr(); err != nil {
+		return
+	}
+	if cap(w.buf) < 4*len(frames) {
+		w.buf = make([]byte, 4*len(frames))
+	}
+	w.iov = w.iov[:0]
+	for i, frame := range frames {
+		prefix := w.buf[4*i : 4*i+4]
+		binary.BigEndian.PutUint32(prefix, uint32(len(frame)))
+		w.iov = append(w.iov, prefix, frame)
+		n += len(frame)
+	}
+	err = w.writeIOV(4*len(frames) + n)
+	if err != nil {
+		return 0, err
+	}
+	if w.opt.Metrics != nil {
+		for _, frame := range frames {
+			w.opt.Metrics.DataFrame(len(frame))
+		}
+	}
+	err = w.wrote(len(frames))
+	return
+}
+
+// WriteFrameV writes a single data frame consisting of the concatenation of
+// parts, without first copying them together. It is otherwise like
+// WriteFrames.
+func (w *Writer) WriteFrameV(parts ...[]byte) (n int, err error) {
+	w.lock()
+	defer w.unlock()
+	if err = w.timerErr(); err != nil {
+		return
+	}
+	for _, part := range parts {
+		n += len(part)
+	}
+	binary.BigEndian.PutUint32(w.buf[:4], uint32(n))
+	w.iov = append(w.iov[:0], w.buf[:4])
+	w.iov = append(w.iov, parts...)
+	err = w.writeIOV(4 + n)
+	if err != nil {
+		return 0, err
+	}
+	if w.opt.Metrics != nil {
+		w.opt.Metrics.DataFrame(n)
+	}
+	err = w.wrote(1)
+	return
+}
+
+// minVectorPart is the smallest average part size for which writeIOV uses
+// a vectored write.
+const minVectorPart = 256
+
+// writeIOV writes the size bytes held in w.iov.
+func (w *Writer) writeIOV(size int) (err error) {
+	defer func() {
+		// Do not retain the caller's frames.
+		for i := range w.iov {
+			w.iov[i] = nil
+		}
+	}()
+
+	// Copying small parts into the buffer is cheaper than a vectored
+	// write with many entries.
+	if w.conn == nil || size <= w.w.Available() || size < len(w.iov)*minVectorPart {
+		for _, b := range w.iov {
+			if _, err = w.w.Write(b); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	if err = w.w.Flush(); err != nil {
+		return
+	}
+	if w.tc != nil {
+		err = w.tc.setDeadline(w.tc.conn.SetWriteDeadline, w.tc.writeTimeout, &w.tc.writeSet)
+		if err != nil {
+			return
+		}
+	}
+	bufs := net.Buffers(w.iov)
+	_, err = bufs.WriteTo(w.conn)
+	return
+}
+
+// WriteFrameFrom writes a data frame of length n, with contents read from r,
+// to the underlying io.Writer with Frame Streams framing. The contents are
+// copied without being buffered in full. If r supplies fewer than n bytes,
+// WriteFrameFrom returns io.ErrUnexpectedEOF, and the stream is corrupt.
+func (w *Writer) WriteFrameFrom(r io.Reader, n uint32) (written int64, err error) {
+	w.lock()
+	defer w.unlock()
+	if err = w.timerErr(); err != nil {
+		return
+	}
+	binary.BigEndian.PutUint32(w.buf[:4], n)
+	_, err = w.w.Write(w.buf[:4])
+	if err != nil {
+		return
+	}
+	written, err = io.CopyN(w.w, r, int64(n))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+		logWarn(w.log, "stream truncated", err,
+			slog.Uint64("length", uint64(n)))
+	}
+	if err != nil {
+		return
+	}
+	if w.opt.Metrics != nil {
+		w.opt.Metrics.DataFrame(int(written))
+	}
+	err = w.wrote(1)
+	return
+}
+
+// WriteFrameContext is like WriteFrame, but returns ctx.Err() if ctx is
+// done before the frame is buffered or written. If a write is interrupted,
+// the Writer keeps the error and cannot be used further.
+func (w *Writer) WriteFrameContext(ctx context.Context, frame []byte) (n int, err error) {
+	err = w.withContext(ctx, func() error {
+		n, err = w.writeFrame(frame)
+		return err
+	})
+	return
 }
 
 // Flush ensures that any buffered data frames are written to the underlying
 // io.Writer.
 func (w *Writer) Flush() error {
-	return w.w.Flush()
+	w.lock()
+	defer w.unlock()
+	return w.flush()
+}
+
+func (w *Writer) flush() error {
+	w.pending = 0
+	if w.armed {
+		w.timer.Stop()
+		w.armed = false
+	}
+	if w.opt.Metrics == nil {
+		return w.w.Flush()
+	}
+	began := time.Now()
+	err := w.w.Flush()
+	w.opt.Metrics.Flush(time.Since(began))
+	return err
+}
+
+// FlushContext is like Flush, but returns ctx.Err() if ctx is done before
+// the buffered frames are written. If a write is interrupted, the Writer
+// keeps the error and cannot be used further.
+func (w *Writer) FlushContext(ctx context.Context) error {
+	return w.withContext(ctx, w.flush)
+}
+
+// writeFramed writes b, holding the given number of complete data frames
+// with their length prefixes, as one write.
+func (w *Writer) writeFramed(b []byte, frames int) error {
+	w.lock()
+	defer w.unlock()
+	if err := w.timerErr(); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(b); err != nil {
+		return err
+	}
+	return w.wrote(frames)
+}
+
+// wrote applies the FlushPolicy after the given number of data frames
+// are written.
+func (w *Writer) wrote(frames int) error {
+	p := &w.opt.FlushPolicy
+	w.pending += frames
+	if p.EveryFrame ||
+		(p.Frames > 0 && w.pending >= p.Frames) ||
+		(p.Bytes > 0 && w.w.Buffered() >= p.Bytes) {
+		return w.flush()
+	}
+	if p.Latency > 0 && !w.armed && w.w.Buffered() > 0 {
+		if w.timer == nil {
+			w.timer = time.AfterFunc(p.Latency, w.flushTimer)
+		} else {
+			w.timer.Reset(p.Latency)
+		}
+		w.armed = true
+	}
+	return nil
+}
+
+// flushTimer flushes frames buffered for the FlushPolicy's Latency,
+// keeping any error for the next write.
+func (w *Writer) flushTimer() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.armed {
+		// Flushed or closed since the timer fired.
+		return
+	}
+	w.armed = false
+	if err := w.flush(); err != nil && w.flushErr == nil {
+		w.flushErr = err
+	}
+}
+
+// timerErr returns, once, an error from the flush timer.
+func (w *Writer) timerErr() error {
+	err := w.flushErr
+	w.flushErr = nil
+	return err
+}
+
+// lock locks the Writer against its flush timer, if it has one.
+func (w *Writer) lock() {
+	if w.opt.FlushPolicy.Latency > 0 {
+		w.mu.Lock()
+	}
+}
+
+func (w *Writer) unlock() {
+	if w.opt.FlushPolicy.Latency > 0 {
+		w.mu.Unlock()
+	}
+}
+
+// withContext calls f, which must not lock the Writer, with I/O bounded by
+// ctx. The Writer stays locked until ctx is unbound, so that the flush timer
+// neither runs with ctx's deadline nor has its deadlines cleared.
+func (w *Writer) withContext(ctx context.Context, f func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	w.lock()
+	defer w.unlock()
+	if w.tc != nil {
+		defer w.tc.bind(ctx)()
+	}
+	return contextErr(ctx, f())
 }
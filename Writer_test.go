@@ -0,0 +1,151 @@
+package framestream_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+// flushRecorder counts the bytes written to it, and fails writes once
+// failing is set.
+type flushRecorder struct {
+	mu      sync.Mutex
+	n       int
+	writes  int
+	failing bool
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (fr *flushRecorder) Write(b []byte) (int, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.writes++
+	if fr.failing {
+		return 0, errWriteFailed
+	}
+	fr.n += len(b)
+	return len(b), nil
+}
+
+func (fr *flushRecorder) written() (n, writes int) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.n, fr.writes
+}
+
+func (fr *flushRecorder) fail() {
+	fr.mu.Lock()
+	fr.failing = true
+	fr.mu.Unlock()
+}
+
+func TestFlushPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy framestream.FlushPolicy
+		// flushed gives whether the frames are flushed after each of
+		// four frames of 30 bytes is written.
+		flushed [4]bool
+	}{
+		{"none", framestream.FlushPolicy{}, [4]bool{false, false, false, false}},
+		{"every", framestream.FlushPolicy{EveryFrame: true}, [4]bool{true, true, true, true}},
+		{"frames", framestream.FlushPolicy{Frames: 2}, [4]bool{false, true, false, true}},
+		{"bytes", framestream.FlushPolicy{Bytes: 100}, [4]bool{false, false, true, false}},
+		{"frames and bytes", framestream.FlushPolicy{Frames: 2, Bytes: 60}, [4]bool{false, true, false, true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fr := new(flushRecorder)
+			w, err := framestream.NewWriter(fr, &framestream.WriterOptions{FlushPolicy: tc.policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			handshake, _ := fr.written()
+			total := handshake
+			for i, flushed := range tc.flushed {
+				if _, err := w.WriteFrame(make([]byte, 30)); err != nil {
+					t.Fatal(err)
+				}
+				n, _ := fr.written()
+				if flushed {
+					total = handshake + 34*(i+1)
+				}
+				if n != total {
+					t.Errorf("frame %d: %d bytes written, expected %d", i, n, total)
+				}
+			}
+		})
+	}
+}
+
+func TestFlushPolicyLatency(t *testing.T) {
+	fr := new(flushRecorder)
+	w, err := framestream.NewWriter(fr, &framestream.WriterOptions{
+		FlushPolicy: framestream.FlushPolicy{Latency: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handshake, writes := fr.written()
+
+	waitWrite := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if _, n := fr.written(); n > writes {
+				writes = n
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("frames not flushed")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for i := 1; i <= 2; i++ {
+		if _, err := w.WriteFrame(make([]byte, 30)); err != nil {
+			t.Fatal(err)
+		}
+		waitWrite()
+		if n, _ := fr.written(); n != handshake+34*i {
+			t.Fatalf("%d bytes written after %d frames", n, i)
+		}
+	}
+
+	// An error flushing from the timer is returned by the next write.
+	fr.fail()
+	if _, err := w.WriteFrame(make([]byte, 30)); err != nil {
+		t.Fatal(err)
+	}
+	waitWrite()
+	if _, err := w.WriteFrame(make([]byte, 30)); !errors.Is(err, errWriteFailed) {
+		t.Fatalf("expected flush error, received %v", err)
+	}
+}
+
+func TestFlushPolicySyncWriter(t *testing.T) {
+	fr := new(flushRecorder)
+	w, err := framestream.NewWriter(fr, &framestream.WriterOptions{
+		FlushPolicy: framestream.FlushPolicy{Frames: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handshake, _ := fr.written()
+	sw := framestream.NewSyncWriter(w, &framestream.SyncWriterOptions{Shards: 1, ShardSize: 1024})
+	p := sw.Producer()
+	for i := 0; i < 31; i++ {
+		if _, err := p.WriteFrame(make([]byte, 30)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The shard holds 30 frames of 34 bytes. The 31st frame writes them
+	// out, and the policy flushes them.
+	if n, _ := fr.written(); n-handshake != 30*34 {
+		t.Errorf("%d bytes written, expected %d", n-handshake, 30*34)
+	}
+}
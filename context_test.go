@@ -0,0 +1,203 @@
+package framestream_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+func TestNewReaderContextTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// The peer never sends READY.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := framestream.NewReaderContext(ctx, server,
+		&framestream.ReaderOptions{Bidirectional: true})
+	if err != context.DeadlineExceeded {
+		t.Errorf("NewReaderContext returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestNewWriterContextCancel(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// The peer never reads READY.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err := framestream.NewWriterContext(ctx, client,
+		&framestream.WriterOptions{Bidirectional: true})
+	if err != context.Canceled {
+		t.Errorf("NewWriterContext returned %v, expected %v", err, context.Canceled)
+	}
+}
+
+func TestReadFrameContext(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	writers := make(chan *framestream.Writer)
+	go func() {
+		w, err := framestream.NewWriter(client,
+			&framestream.WriterOptions{Bidirectional: true})
+		if err != nil {
+			t.Error(err)
+		}
+		writers <- w
+	}()
+	r, err := framestream.NewReaderContext(context.Background(), server,
+		&framestream.ReaderOptions{Bidirectional: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := <-writers
+	if w == nil {
+		t.FailNow()
+	}
+
+	// An idle stream is interrupted by the context.
+	buf := make([]byte, 16)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.ReadFrameContext(ctx, buf); err != context.DeadlineExceeded {
+		t.Fatalf("ReadFrameContext returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	// The deadline does not outlive the call.
+	go func() {
+		w.WriteFrameContext(context.Background(), []byte("hello"))
+		w.FlushContext(context.Background())
+	}()
+	time.Sleep(100 * time.Millisecond)
+	n, err := r.ReadFrameContext(context.Background(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf[:n], []byte("hello")) {
+		t.Errorf("received %q, expected %q", buf[:n], "hello")
+	}
+}
+
+func TestContextKeepsCallerDeadline(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		w, err := framestream.NewWriter(client, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.WriteFrame([]byte("hello"))
+		w.Flush()
+	}()
+	r, err := framestream.NewReader(server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The caller's own deadline survives a call with a context.
+	server.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	buf := make([]byte, 16)
+	if _, err := r.ReadFrameContext(ctx, buf); err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(5*time.Second, func() { server.Close() })
+	if _, err := r.ReadFrame(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("ReadFrame returned %v, expected %v", err, os.ErrDeadlineExceeded)
+	}
+}
+
+func TestCloseContext(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go framestream.NewReader(server, &framestream.ReaderOptions{Bidirectional: true})
+	w, err := framestream.NewWriter(client,
+		&framestream.WriterOptions{Bidirectional: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Reader no longer reads, so CONTROL_STOP is never delivered.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := w.CloseContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("CloseContext returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestContextDoneBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := framestream.NewWriterContext(ctx, new(bytes.Buffer), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("NewWriterContext returned %v, expected %v", err, context.Canceled)
+	}
+	if _, err := framestream.NewReaderContext(ctx, new(bytes.Buffer), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("NewReaderContext returned %v, expected %v", err, context.Canceled)
+	}
+}
+
+func TestContextFlushLatency(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	const nframes = 2000
+	done := make(chan int)
+	go func() {
+		n := 0
+		defer func() { done <- n }()
+		r, err := framestream.NewReader(server, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for {
+			if _, err := r.Next(); err != nil {
+				return
+			}
+			n++
+		}
+	}()
+
+	w, err := framestream.NewWriter(client, &framestream.WriterOptions{
+		FlushPolicy: framestream.FlushPolicy{Latency: 50 * time.Microsecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for i := 0; i < nframes; i++ {
+		if _, err := w.WriteFrameContext(ctx, []byte("frame")); err != nil {
+			t.Fatal(err)
+		}
+		if i%100 == 0 {
+			if err := w.FlushContext(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.CloseContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := <-done; n != nframes {
+		t.Errorf("read %d frames, expected %d", n, nframes)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Phase identifies the part of a Frame Streams session in which an error
+// occurred.
+type Phase int
+
+const (
+	// PhaseHandshake covers the READY, ACCEPT and START frames.
+	PhaseHandshake Phase = iota
+	// PhaseData covers data frames and the STOP frame ending them.
+	PhaseData
+	// PhaseShutdown covers the FINISH frame acknowledging STOP.
+	PhaseShutdown
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseHandshake:
+		return "handshake"
+	case PhaseData:
+		return "data"
+	case PhaseShutdown:
+		return "shutdown"
+	}
+	return "unknown"
+}
+
+// A ProtocolError describes a malformed or unexpected frame, and where in
+// the stream it was found. It wraps one of ErrDecode, ErrContentTypeMismatch,
+// ErrDataFrameTooLarge or io.ErrUnexpectedEOF, or the error with which an
+// OnControlFrame hook rejected a frame received, so that errors.Is may be
+// used to test for those errors.
+type ProtocolError struct {
+	Err   error
+	Phase Phase
+	// Offset is the byte offset in the received stream of the start of
+	// the offending frame.
+	Offset int64
+	// Frame is the index of the offending frame among the frames
+	// received, counting control frames and starting from zero.
+	Frame int64
+	// ExpectedType and ActualType give the expected and received control
+	// types when an unexpected control frame is received, and are
+	// otherwise zero.
+	ExpectedType, ActualType uint32
+	// Length is the length of the offending frame, if known.
+	Length uint32
+}
+
+func (e *ProtocolError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Err.Error())
+	if e.ExpectedType != 0 && e.ActualType != e.ExpectedType {
+		fmt.Fprintf(&b, ": expected %s, received %s",
+			ControlTypeName(e.ExpectedType), ControlTypeName(e.ActualType))
+	}
+	if e.Length != 0 {
+		fmt.Fprintf(&b, ": length %d", e.Length)
+	}
+	fmt.Fprintf(&b, " (%s frame %d at offset %d)", e.Phase, e.Frame, e.Offset)
+	return b.String()
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+// unwrapProtocolError returns the error wrapped by err if it is a
+// *ProtocolError. Decoder and Encoder predate ProtocolError, and return the
+// errors it wraps.
+func unwrapProtocolError(err error) error {
+	if perr, ok := err.(*ProtocolError); ok {
+		return perr.Err
+	}
+	return err
+}
+
+// ControlTypeName returns the protocol name of a control type, such as
+// "START", or its number if the type is unknown.
+func ControlTypeName(controlType uint32) string {
+	switch controlType {
+	case CONTROL_ACCEPT:
+		return "ACCEPT"
+	case CONTROL_START:
+		return "START"
+	case CONTROL_STOP:
+		return "STOP"
+	case CONTROL_READY:
+		return "READY"
+	case CONTROL_FINISH:
+		return "FINISH"
+	}
+	return fmt.Sprintf("0x%x", controlType)
+}
+
+// streamPos tracks the position of a frame reader in its input.
+type streamPos struct {
+	r io.Reader
+	n int64
+	// off and frame give the offset and index of the frame being read.
+	off   int64
+	frame int64
+}
+
+func newStreamPos(r io.Reader) *streamPos {
+	return &streamPos{r: r, frame: -1}
+}
+
+func (p *streamPos) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	return n, err
+}
+
+// begin records the start of a new frame, given the number of bytes
+// read from p but not yet consumed.
+func (p *streamPos) begin(buffered int) {
+	p.off = p.n - int64(buffered)
+	p.frame++
+}
+
+// newError returns a *ProtocolError for the current frame.
+func (p *streamPos) newError(err error, phase Phase) *ProtocolError {
+	return &ProtocolError{
+		Err:    err,
+		Phase:  phase,
+		Offset: p.off,
+		Frame:  p.frame,
+	}
+}
+
+// wrap returns err as a *ProtocolError for the current frame if it is one
+// of the protocol errors, and otherwise returns it unchanged.
+func (p *streamPos) wrap(err error, phase Phase) error {
+	for _, perr := range []error{ErrDecode, ErrContentTypeMismatch, ErrDataFrameTooLarge, io.ErrUnexpectedEOF} {
+		if errors.Is(err, perr) {
+			return p.newError(err, phase)
+		}
+	}
+	return err
+}
+
+// readControl reads an escaped control frame of the given type into cf.
+func (p *streamPos) readControl(r *bufio.Reader, cf *ControlFrame, ctype uint32, phase Phase) error {
+	p.begin(r.Buffered())
+	if err := cf.DecodeEscape(r); err != nil {
+		return p.wrap(err, phase)
+	}
+	if cf.ControlType != ctype {
+		perr := p.newError(ErrDecode, phase)
+		perr.ExpectedType = ctype
+		perr.ActualType = cf.ControlType
+		return perr
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package framestream_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+func TestProtocolErrorHandshake(t *testing.T) {
+	// A stream beginning with STOP rather than START.
+	buf := new(bytes.Buffer)
+	framestream.ControlStop.Encode(buf)
+
+	_, err := framestream.NewReader(buf, nil)
+	var perr *framestream.ProtocolError
+	if !errors.As(err, &perr) {
+		t.Fatalf("NewReader returned %v, expected *ProtocolError", err)
+	}
+	if !errors.Is(err, framestream.ErrDecode) {
+		t.Errorf("%v does not wrap %v", err, framestream.ErrDecode)
+	}
+	if perr.Phase != framestream.PhaseHandshake ||
+		perr.ExpectedType != framestream.CONTROL_START ||
+		perr.ActualType != framestream.CONTROL_STOP ||
+		perr.Offset != 0 || perr.Frame != 0 {
+		t.Errorf("unexpected error fields: %+v", *perr)
+	}
+}
+
+func TestProtocolErrorData(t *testing.T) {
+	stream := testStream(t, "", 5, 40, 7).Bytes()
+	// START: 4 + 4 + 4 bytes. Data frames: 4 + length bytes each.
+	const start = 12
+
+	r, err := framestream.NewReader(bytes.NewReader(stream), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	if _, err := r.ReadFrame(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.ReadFrame(buf)
+	var perr *framestream.ProtocolError
+	if !errors.As(err, &perr) || !errors.Is(err, framestream.ErrDataFrameTooLarge) {
+		t.Fatalf("ReadFrame returned %v, expected ErrDataFrameTooLarge", err)
+	}
+	if perr.Phase != framestream.PhaseData || perr.Length != 40 ||
+		perr.Offset != start+4+5 || perr.Frame != 2 {
+		t.Errorf("unexpected error fields: %+v", *perr)
+	}
+
+	// Truncate the stream in the middle of the last data frame.
+	r, err = framestream.NewReader(bytes.NewReader(stream[:start+9+44+6]), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Next()
+	r.Next()
+	_, err = r.Next()
+	if !errors.As(err, &perr) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Next returned %v, expected io.ErrUnexpectedEOF", err)
+	}
+	if perr.Length != 7 || perr.Offset != start+9+44 || perr.Frame != 3 {
+		t.Errorf("unexpected error fields: %+v", *perr)
+	}
+}
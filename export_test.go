@@ -0,0 +1,12 @@
+package framestream
+
+// UnregisterControlField removes the validator of fieldType, so that tests
+// in package framestream_test may register it more than once.
+func UnregisterControlField(fieldType uint32) {
+	fieldRegistry.Lock()
+	delete(fieldRegistry.validators, fieldType)
+	fieldRegistry.Unlock()
+}
+
+// NextBoundary exposes the rotation boundaries of RotatingFileWriter.
+var NextBoundary = nextBoundary
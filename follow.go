@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+const defaultFollowInterval = 250 * time.Millisecond
+
+// follower reads a file which is still being written, waiting at its end
+// for more data rather than returning io.EOF.
+type follower struct {
+	file     *os.File
+	off      int64
+	interval time.Duration
+	rotated  bool
+	// ctx, if set, bounds the wait for more data.
+	ctx context.Context
+}
+
+func newFollower(file *os.File, interval time.Duration) *follower {
+	if interval <= 0 {
+		interval = defaultFollowInterval
+	}
+	off, _ := file.Seek(0, io.SeekCurrent)
+	return &follower{file: file, off: off, interval: interval}
+}
+
+func (f *follower) Read(b []byte) (int, error) {
+	for {
+		n, err := f.file.Read(b)
+		f.off += int64(n)
+		if n > 0 || err != io.EOF {
+			f.rotated = false
+			return n, err
+		}
+		if err := f.check(); err != nil {
+			return 0, err
+		}
+		if err := f.wait(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// check returns ErrFileTruncated if the file has shrunk, and
+// ErrFileRotated if its name has referred to another file for a full
+// interval with no more data written to it.
+func (f *follower) check() error {
+	fi, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < f.off {
+		return ErrFileTruncated
+	}
+	ni, err := os.Stat(f.file.Name())
+	if err == nil && os.SameFile(fi, ni) {
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// The writer may yet finish the file after it is renamed, so allow
+	// it one interval to do so.
+	if f.rotated {
+		return ErrFileRotated
+	}
+	f.rotated = true
+	return nil
+}
+
+func (f *follower) wait() error {
+	if f.ctx == nil {
+		time.Sleep(f.interval)
+		return nil
+	}
+	t := time.NewTimer(f.interval)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-f.ctx.Done():
+		return f.ctx.Err()
+	}
+}
+
+// bind bounds waits for more data by ctx until the returned function is
+// called.
+func (f *follower) bind(ctx context.Context) (unbind func()) {
+	f.ctx = ctx
+	return func() { f.ctx = nil }
+}
@@ -0,0 +1,126 @@
+package framestream_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+func followOptions() *framestream.ReaderOptions {
+	return &framestream.ReaderOptions{
+		Follow:         true,
+		FollowInterval: time.Millisecond,
+	}
+}
+
+func TestFollow(t *testing.T) {
+	stream := testStream(t, "test", frameSizes(10)...).Bytes()
+	name := filepath.Join(t.TempDir(), "follow.fstrm")
+	out, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	// Write the stream a few bytes at a time, so that the Reader meets
+	// partial frames and the end of the file repeatedly.
+	go func() {
+		for b := stream; len(b) > 0; {
+			n := 3
+			if n > len(b) {
+				n = len(b)
+			}
+			if _, err := out.Write(b[:n]); err != nil {
+				t.Error(err)
+				return
+			}
+			b = b[n:]
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+
+	in, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	r, err := framestream.NewReader(in, followOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		frame, err := r.Next()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if len(frame) != i+1 {
+			t.Fatalf("frame %d: length %d", i, len(frame))
+		}
+	}
+	if _, err := r.Next(); err != framestream.EOF {
+		t.Errorf("expected EOF, received %v", err)
+	}
+}
+
+// openFollowed writes a stream without its STOP frame to a new file, and
+// returns the file's name and a following Reader which has read the
+// stream's data frames.
+func openFollowed(t *testing.T) (string, *framestream.Reader) {
+	stream := testStream(t, "test", frameSizes(3)...).Bytes()
+	name := filepath.Join(t.TempDir(), "follow.fstrm")
+	if err := os.WriteFile(name, stream[:len(stream)-stopFrameLen], 0644); err != nil {
+		t.Fatal(err)
+	}
+	in, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { in.Close() })
+	r, err := framestream.NewReader(in, followOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := r.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return name, r
+}
+
+func TestFollowTruncated(t *testing.T) {
+	name, r := openFollowed(t)
+	if err := os.Truncate(name, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); !errors.Is(err, framestream.ErrFileTruncated) {
+		t.Errorf("expected ErrFileTruncated, received %v", err)
+	}
+}
+
+func TestFollowRotated(t *testing.T) {
+	name, r := openFollowed(t)
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); !errors.Is(err, framestream.ErrFileRotated) {
+		t.Errorf("expected ErrFileRotated, received %v", err)
+	}
+}
+
+func TestFollowContext(t *testing.T) {
+	_, r := openFollowed(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.ReadFrameContext(ctx, make([]byte, 16)); err != context.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded, received %v", err)
+	}
+}
@@ -30,3 +30,13 @@ var ErrDataFrameTooLarge = errors.New("data frame too large")
 var ErrShortRead = errors.New("short read")
 var ErrDecode = errors.New("decoding error")
 var ErrType = errors.New("invalid type")
+var ErrServerClosed = errors.New("server closed")
+var ErrNotConnected = errors.New("not connected")
+var ErrWriterClosed = errors.New("writer closed")
+var ErrQueueFull = errors.New("queue full")
+var ErrNoPattern = errors.New("no file name pattern")
+var ErrControlFrameTooLarge = errors.New("control frame too large")
+var ErrIndexMismatch = errors.New("index does not match file")
+var ErrFrameRange = errors.New("frame out of range")
+var ErrFileTruncated = errors.New("file truncated")
+var ErrFileRotated = errors.New("file rotated")
@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/farsightsec/golang-framestream"
+)
+
+var (
+	contentType   = flag.String("type", "", "content type to negotiate with writers")
+	unixPath      = flag.String("unix", "", "Unix socket path to listen on")
+	tcpAddr       = flag.String("tcp", "", "TCP address to listen on")
+	output        = flag.String("w", "", "output file name, with strftime-style % conversions")
+	splitInterval = flag.Duration("split-interval", 0, "start a new output file at this interval")
+	splitBytes    = flag.Int64("split-bytes", 0, "start a new output file after this many bytes")
+	maxFrameSize  = flag.Uint("max-frame-size", framestream.DEFAULT_MAX_PAYLOAD_SIZE, "largest data frame accepted")
+	flushInterval = flag.Duration("flush-interval", time.Second, "longest time a frame is buffered before being written")
+)
+
+// A capture writes the frames of all incoming streams to a single
+// RotatingFileWriter. Once closed, it drops the frames of streams which
+// outlived the shutdown.
+type capture struct {
+	mu     sync.Mutex
+	w      *framestream.RotatingFileWriter
+	closed bool
+}
+
+func (c *capture) ServeFrames(ctx context.Context, r *framestream.Reader, conn net.Conn) {
+	log.Printf("%v: stream started", conn.RemoteAddr())
+	var frames int
+	for {
+		frame, err := r.Next()
+		if err == framestream.EOF {
+			break
+		}
+		if errors.Is(err, framestream.ErrDataFrameTooLarge) {
+			log.Printf("%v: %v", conn.RemoteAddr(), err)
+			continue
+		}
+		if err != nil {
+			log.Printf("%v: %v", conn.RemoteAddr(), err)
+			break
+		}
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			log.Printf("%v: output closed, dropping stream", conn.RemoteAddr())
+			return
+		}
+		_, err = c.w.WriteFrame(frame)
+		c.mu.Unlock()
+		if err != nil {
+			log.Fatalf("%s: %v", c.w.Path(), err)
+		}
+		frames++
+	}
+	log.Printf("%v: stream ended after %d frames", conn.RemoteAddr(), frames)
+}
+
+func (c *capture) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.w.Flush(); err != nil {
+		log.Fatalf("%s: %v", c.w.Path(), err)
+	}
+}
+
+func (c *capture) rotate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.w.Rotate(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("writing to %s", c.w.Path())
+}
+
+func (c *capture) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return c.w.Close()
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s -type TYPE (-unix PATH | -tcp ADDR) -w FILE [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Captures Frame Streams connections to files.\n")
+		fmt.Fprintf(os.Stderr, "SIGHUP starts a new output file.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Arguments.
+	if *contentType == "" || *output == "" || (*unixPath == "") == (*tcpAddr == "") || flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	// Open the output.
+	w, err := framestream.NewRotatingFileWriter(&framestream.RotatingFileWriterOptions{
+		Pattern:     *output,
+		ContentType: []byte(*contentType),
+		MaxBytes:    *splitBytes,
+		Interval:    *splitInterval,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("writing to %s", w.Path())
+
+	// Open the listener.
+	var l net.Listener
+	if *unixPath != "" {
+		os.Remove(*unixPath)
+		l, err = net.Listen("unix", *unixPath)
+	} else {
+		l, err = net.Listen("tcp", *tcpAddr)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ropt := &framestream.ReaderOptions{
+		Bidirectional: true,
+		Timeout:       10 * time.Second,
+		MaxFrameSize:  uint32(*maxFrameSize),
+		ContentTypes:  [][]byte{[]byte(*contentType)},
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	if err := run(l, w, ropt, sigs); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves the connections accepted on l, writing their frames to w,
+// until SIGINT or SIGTERM is received on sigs. SIGHUP starts a new output
+// file.
+func run(l net.Listener, w *framestream.RotatingFileWriter, ropt *framestream.ReaderOptions, sigs <-chan os.Signal) error {
+	c := &capture{w: w}
+	s := &framestream.Server{
+		Handler:       c,
+		ReaderOptions: ropt,
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(l)
+	}()
+	log.Printf("listening on %v", l.Addr())
+
+	ticker := time.NewTicker(*flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.flush()
+		case err := <-served:
+			return err
+		case sig := <-sigs:
+			if sig == syscall.SIGHUP {
+				c.rotate()
+				continue
+			}
+			log.Printf("%v: shutting down", sig)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			s.Shutdown(ctx)
+			cancel()
+			return c.close()
+		}
+	}
+}
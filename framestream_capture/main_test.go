@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+// sendFrames writes a stream of frames of 10 bytes, numbered from first,
+// to the Unix socket at path.
+func sendFrames(t *testing.T, path string, first, n int) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	w, err := framestream.NewWriter(conn, &framestream.WriterOptions{
+		Bidirectional: true,
+		ContentTypes:  [][]byte{[]byte("test")},
+		Timeout:       5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := first; i < first+n; i++ {
+		if _, err := w.WriteFrame([]byte(strconv.Itoa(1000000000 + i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The FINISH frame arrives once the frames have been captured.
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readCapture returns the content type and frames of a captured file.
+func readCapture(t *testing.T, path string) (string, []string) {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r, err := framestream.NewReader(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frames []string
+	for {
+		frame, err := r.Next()
+		if err == framestream.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		frames = append(frames, string(frame))
+	}
+	return string(r.ContentType()), frames
+}
+
+func TestCapture(t *testing.T) {
+	// Unix socket paths are short, so the socket is not in t.TempDir().
+	sockDir, err := os.MkdirTemp("", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(sockDir)
+	sock := filepath.Join(sockDir, "s")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "capture.fstrm")
+	w, err := framestream.NewRotatingFileWriter(&framestream.RotatingFileWriterOptions{
+		Pattern:     out,
+		ContentType: []byte("test"),
+		MaxBytes:    100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ropt := &framestream.ReaderOptions{
+		Bidirectional: true,
+		ContentTypes:  [][]byte{[]byte("test")},
+	}
+	sigs := make(chan os.Signal)
+	ran := make(chan error, 1)
+	go func() {
+		ran <- run(l, w, ropt, sigs)
+	}()
+
+	// 14 bytes of each frame with its length prefix, so 7 frames to a
+	// file.
+	sendFrames(t, sock, 0, 20)
+
+	// SIGHUP starts a new file.
+	sigs <- syscall.SIGHUP
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(out + ".3"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no new output file after SIGHUP")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	sendFrames(t, sock, 20, 3)
+
+	sigs <- syscall.SIGTERM
+	if err := <-ran; err != nil {
+		t.Fatal(err)
+	}
+
+	next := 0
+	for i, path := range []string{out, out + ".1", out + ".2", out + ".3"} {
+		ctype, frames := readCapture(t, path)
+		if ctype != "test" {
+			t.Errorf("%s: content type %q", path, ctype)
+		}
+		expected := []int{7, 7, 6, 3}[i]
+		if len(frames) != expected {
+			t.Errorf("%s: %d frames, expected %d", path, len(frames), expected)
+		}
+		for _, frame := range frames {
+			if frame != strconv.Itoa(1000000000+next) {
+				t.Errorf("%s: frame %q, expected %d", path, frame, 1000000000+next)
+			}
+			next++
+		}
+	}
+	if _, err := os.Stat(out + ".4"); err == nil {
+		t.Errorf("unexpected output file %s.4", out)
+	}
+}
+
+func TestCaptureClosed(t *testing.T) {
+	w, err := framestream.NewRotatingFileWriter(&framestream.RotatingFileWriterOptions{
+		Pattern:     filepath.Join(t.TempDir(), "capture.fstrm"),
+		ContentType: []byte("test"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &capture{w: w}
+	if err := c.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	fw, err := framestream.NewWriter(buf, &framestream.WriterOptions{
+		ContentTypes: [][]byte{[]byte("test")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.WriteFrame([]byte("late"))
+	fw.Close()
+	r, err := framestream.NewReader(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A stream outliving the shutdown is dropped rather than written to
+	// the closed output, which would exit.
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+	c.ServeFrames(context.Background(), r, conn)
+}
@@ -17,43 +17,261 @@
 package main
 
 import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 
 	"github.com/farsightsec/golang-framestream"
 )
 
-func main() {
-	// Arguments.
-	if len(os.Args) != 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <INPUT FILE>\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "Dumps a FrameStreams formatted input file.\n\n")
-		os.Exit(1)
+var (
+	format = flag.String("format", "hex", "output format: hex, hexdump, base64, ndjson or raw")
+	start  = flag.Int64("start", 0, "index of the first data frame to print, found using INPUT FILE.idx if present")
+	follow = flag.Bool("f", false, "wait for more data at the end of the file until the stream ends")
+)
+
+// A printer writes the header and data frames of a stream in one format.
+type printer interface {
+	header(ctype []byte) error
+	frame(index, offset int64, frame []byte) error
+}
+
+// A frameSource is a *framestream.Reader or *framestream.IndexedReader.
+type frameSource interface {
+	Next() ([]byte, error)
+	Offset() int64
+	ContentType() []byte
+}
+
+// openIndexed returns an IndexedReader positioned at data frame n of file,
+// using the index written by framestream_index.
+func openIndexed(file *os.File, n int64) (*framestream.IndexedReader, error) {
+	f, err := os.Open(file.Name() + ".idx")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	idx, err := framestream.ReadIndex(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	ir, err := framestream.NewIndexedReader(file, fi.Size(), idx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	return ir, ir.SeekFrame(n)
+}
+
+// newPrinter returns a printer for the named format writing to w, or nil
+// if the format is unknown.
+func newPrinter(format string, w io.Writer) printer {
+	switch format {
+	case "hex":
+		return hexPrinter{w}
+	case "hexdump":
+		return hexdumpPrinter{w}
+	case "base64":
+		return base64Printer{w}
+	case "ndjson":
+		return ndjsonPrinter{json.NewEncoder(w)}
+	case "raw":
+		return rawPrinter{w}
+	}
+	return nil
+}
+
+// dumpOptions holds the settings of the command line flags.
+type dumpOptions struct {
+	format string
+	start  int64
+	follow bool
+}
+
+// dump prints the content type and data frames of the file fname to w.
+func dump(w io.Writer, fname string, opt dumpOptions) error {
+	out := bufio.NewWriter(w)
+	p := newPrinter(opt.format, out)
+	if p == nil {
+		return fmt.Errorf("unknown format %q", opt.format)
 	}
-	fname := os.Args[1]
 
 	// Open the input file.
 	file, err := os.Open(fname)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer file.Close()
 
-	// Create the decoder.
-	fs, err := framestream.NewDecoder(file, nil)
-	if err != nil {
-		log.Fatal(err)
+	// Create the reader, using an index to find the first frame if
+	// there is one.
+	var fs frameSource
+	index := int64(0)
+	if opt.start > 0 && !opt.follow {
+		ir, err := openIndexed(file, opt.start)
+		if err == nil {
+			fs, index = ir, opt.start
+		} else if !os.IsNotExist(err) {
+			log.Print(err)
+		}
+	}
+	if fs == nil {
+		ropt := &framestream.ReaderOptions{Follow: opt.follow}
+		if fs, err = framestream.NewReader(file, ropt); err != nil {
+			return err
+		}
+	}
+	if err := p.header(fs.ContentType()); err != nil {
+		return err
+	}
+	if opt.follow {
+		if err := out.Flush(); err != nil {
+			return err
+		}
 	}
 
 	// Print the data frames.
-	for {
-		frame, err := fs.Decode()
+	for ; ; index++ {
+		frame, err := fs.Next()
 		if err == framestream.EOF {
 			break
 		}
+		if errors.Is(err, framestream.ErrDataFrameTooLarge) {
+			log.Print(err)
+			continue
+		}
 		if err != nil {
-			log.Fatal(err)
+			out.Flush()
+			return err
+		}
+		if index < opt.start {
+			continue
+		}
+		if err := p.frame(index, fs.Offset(), frame); err != nil {
+			return err
+		}
+		if opt.follow {
+			if err := out.Flush(); err != nil {
+				return err
+			}
 		}
-		fmt.Printf("Data frame (%v bytes): %x\n", len(frame), frame)
 	}
+	return out.Flush()
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-format FORMAT] [-start N] [-f] <INPUT FILE>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Dumps a FrameStreams formatted input file.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Arguments.
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if newPrinter(*format, nil) == nil {
+		fmt.Fprintf(os.Stderr, "%s: unknown format %q\n", os.Args[0], *format)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	err := dump(os.Stdout, flag.Arg(0), dumpOptions{
+		format: *format,
+		start:  *start,
+		follow: *follow,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type hexPrinter struct{ w io.Writer }
+
+func (p hexPrinter) header(ctype []byte) error {
+	_, err := fmt.Fprintf(p.w, "Content type: %q\n", ctype)
+	return err
+}
+
+func (p hexPrinter) frame(index, offset int64, frame []byte) error {
+	_, err := fmt.Fprintf(p.w, "Data frame %d at offset %d (%v bytes): %x\n",
+		index, offset, len(frame), frame)
+	return err
+}
+
+type hexdumpPrinter struct{ w io.Writer }
+
+func (p hexdumpPrinter) header(ctype []byte) error {
+	_, err := fmt.Fprintf(p.w, "Content type: %q\n", ctype)
+	return err
+}
+
+func (p hexdumpPrinter) frame(index, offset int64, frame []byte) error {
+	_, err := fmt.Fprintf(p.w, "\nData frame %d at offset %d (%v bytes):\n",
+		index, offset, len(frame))
+	if err != nil {
+		return err
+	}
+	d := hex.Dumper(p.w)
+	if _, err = d.Write(frame); err != nil {
+		return err
+	}
+	return d.Close()
+}
+
+type base64Printer struct{ w io.Writer }
+
+func (p base64Printer) header(ctype []byte) error {
+	_, err := fmt.Fprintf(p.w, "# content type: %q\n", ctype)
+	return err
+}
+
+func (p base64Printer) frame(index, offset int64, frame []byte) error {
+	_, err := fmt.Fprintf(p.w, "%d %d %s\n",
+		index, offset, base64.StdEncoding.EncodeToString(frame))
+	return err
+}
+
+// ndjsonPrinter writes a header object followed by one object per frame.
+// Frame data is base64 encoded.
+type ndjsonPrinter struct{ enc *json.Encoder }
+
+func (p ndjsonPrinter) header(ctype []byte) error {
+	return p.enc.Encode(struct {
+		ContentType string `json:"content_type"`
+	}{string(ctype)})
+}
+
+func (p ndjsonPrinter) frame(index, offset int64, frame []byte) error {
+	return p.enc.Encode(struct {
+		Index  int64  `json:"index"`
+		Offset int64  `json:"offset"`
+		Length int    `json:"length"`
+		Data   []byte `json:"data"`
+	}{index, offset, len(frame), frame})
+}
+
+// rawPrinter writes the frame payloads alone. The header goes to stderr.
+type rawPrinter struct{ w io.Writer }
+
+func (p rawPrinter) header(ctype []byte) error {
+	_, err := fmt.Fprintf(os.Stderr, "Content type: %q\n", ctype)
+	return err
+}
+
+func (p rawPrinter) frame(index, offset int64, frame []byte) error {
+	_, err := p.w.Write(frame)
+	return err
 }
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+// dumpFrames are the data frames of the test file. With the START frame
+// taking 24 bytes, they are at offsets 24, 29 and 35.
+var dumpFrames = []string{"a", "bc", "def"}
+
+// dumpStream returns the encoded test stream.
+func dumpStream(t *testing.T) []byte {
+	buf := new(bytes.Buffer)
+	w, err := framestream.NewWriter(buf, &framestream.WriterOptions{
+		ContentTypes: [][]byte{[]byte("test")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range dumpFrames {
+		if _, err := w.WriteFrame([]byte(f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeDumpFile(t *testing.T, data []byte) string {
+	fname := filepath.Join(t.TempDir(), "test.fstrm")
+	if err := os.WriteFile(fname, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fname
+}
+
+func TestDump(t *testing.T) {
+	fname := writeDumpFile(t, dumpStream(t))
+
+	for _, tc := range []struct {
+		format   string
+		expected string
+	}{
+		{"hex", `Content type: "test"
+Data frame 0 at offset 24 (1 bytes): 61
+Data frame 1 at offset 29 (2 bytes): 6263
+Data frame 2 at offset 35 (3 bytes): 646566
+`},
+		{"hexdump", `Content type: "test"
+
+Data frame 0 at offset 24 (1 bytes):
+` + hex.Dump([]byte("a")) + `
+Data frame 1 at offset 29 (2 bytes):
+` + hex.Dump([]byte("bc")) + `
+Data frame 2 at offset 35 (3 bytes):
+` + hex.Dump([]byte("def"))},
+		{"base64", `# content type: "test"
+0 24 YQ==
+1 29 YmM=
+2 35 ZGVm
+`},
+		{"ndjson", `{"content_type":"test"}
+{"index":0,"offset":24,"length":1,"data":"YQ=="}
+{"index":1,"offset":29,"length":2,"data":"YmM="}
+{"index":2,"offset":35,"length":3,"data":"ZGVm"}
+`},
+		{"raw", "abcdef"},
+	} {
+		out := new(bytes.Buffer)
+		if err := dump(out, fname, dumpOptions{format: tc.format}); err != nil {
+			t.Errorf("%s: %v", tc.format, err)
+			continue
+		}
+		if out.String() != tc.expected {
+			t.Errorf("%s: output\n%s\nexpected\n%s", tc.format, out, tc.expected)
+		}
+	}
+
+	if err := dump(new(bytes.Buffer), fname, dumpOptions{format: "octal"}); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestDumpStart(t *testing.T) {
+	fname := writeDumpFile(t, dumpStream(t))
+	expected := `# content type: "test"
+2 35 ZGVm
+`
+
+	// Without an index, the earlier frames are read and skipped.
+	out := new(bytes.Buffer)
+	if err := dump(out, fname, dumpOptions{format: "base64", start: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Errorf("output\n%s\nexpected\n%s", out, expected)
+	}
+
+	// With an index, the first frame is found directly.
+	file, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := framestream.BuildIndex(file, fi.Size(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ibuf bytes.Buffer
+	if _, err := idx.WriteTo(&ibuf); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fname+".idx", ibuf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := dump(out, fname, dumpOptions{format: "base64", start: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Errorf("indexed output\n%s\nexpected\n%s", out, expected)
+	}
+}
+
+func TestDumpFollow(t *testing.T) {
+	// The file ends after the second frame until the dump is waiting
+	// for more.
+	stream := dumpStream(t)
+	fname := writeDumpFile(t, stream[:35])
+
+	out := new(bytes.Buffer)
+	dumped := make(chan error, 1)
+	go func() {
+		dumped <- dump(out, fname, dumpOptions{format: "base64", follow: true})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(stream[35:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-dumped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("dump did not end with the stream")
+	}
+	expected := `# content type: "test"
+0 24 YQ==
+1 29 YmM=
+2 35 ZGVm
+`
+	if out.String() != expected {
+		t.Errorf("output\n%s\nexpected\n%s", out, expected)
+	}
+}
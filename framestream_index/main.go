@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/farsightsec/golang-framestream"
+)
+
+var interval = flag.Int64("interval", 1000, "number of data frames between index entries")
+
+// writeIndex indexes the file fname at the given interval, and writes the
+// index to fname.idx.
+func writeIndex(fname string, interval int64) (*framestream.Index, error) {
+	// Open the input file.
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the index.
+	idx, err := framestream.BuildIndex(file, fi.Size(), interval)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+
+	// Write it next to the input file.
+	out, err := os.Create(fname + ".idx")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := idx.WriteTo(out); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return idx, out.Close()
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-interval N] <INPUT FILE>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Writes an index of a FrameStreams formatted input file to INPUT FILE.idx.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Arguments.
+	if flag.NArg() != 1 || *interval <= 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	fname := flag.Arg(0)
+
+	idx, err := writeIndex(fname, *interval)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("indexed %d frames in %s.idx", idx.Frames, fname)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+func writeTestFile(t *testing.T, nframes int) string {
+	fname := filepath.Join(t.TempDir(), "test.fstrm")
+	file, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := framestream.NewWriter(file, &framestream.WriterOptions{
+		ContentTypes: [][]byte{[]byte("test")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < nframes; i++ {
+		if _, err := w.WriteFrame([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return fname
+}
+
+func TestWriteIndex(t *testing.T) {
+	fname := writeTestFile(t, 25)
+	if _, err := writeIndex(fname, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	// The index written is usable to seek in the file.
+	f, err := os.Open(fname + ".idx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	idx, err := framestream.ReadIndex(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Frames != 25 || idx.Interval != 10 || len(idx.Offsets) != 3 {
+		t.Errorf("index of %d frames at interval %d with %d offsets",
+			idx.Frames, idx.Interval, len(idx.Offsets))
+	}
+
+	file, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ir, err := framestream.NewIndexedReader(file, fi.Size(), idx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ir.SeekFrame(23); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := ir.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(frame) != "23" {
+		t.Errorf("frame 23 is %q", frame)
+	}
+}
+
+func TestWriteIndexNotStream(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(fname, []byte("not a Frame Streams file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeIndex(fname, 10); err == nil {
+		t.Error("index written of a file which is not a stream")
+	}
+	if _, err := os.Stat(fname + ".idx"); err == nil {
+		t.Error("index file created")
+	}
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/farsightsec/golang-framestream"
+)
+
+var (
+	contentType   = flag.String("type", "", "content type to offer (default: the content type of the input file)")
+	unixPath      = flag.String("unix", "", "Unix socket path to connect to")
+	tcpAddr       = flag.String("tcp", "", "TCP address to connect to")
+	bidirectional = flag.Bool("bidirectional", true, "use the bidirectional handshake")
+	timeout       = flag.Duration("timeout", 10*time.Second, "timeout for the bidirectional handshake and writes")
+	rate          = flag.Float64("rate", 0, "data frames sent per second, or 0 for no limit")
+	loops         = flag.Int("loop", 1, "number of times to replay the input file, or 0 to repeat until interrupted")
+	start         = flag.Int64("start", 0, "index of the first data frame to send from the input file")
+	count         = flag.Int64("count", 0, "number of data frames to send from each pass over the input file, or 0 for all")
+)
+
+// replayOptions specifies which frames of a file are replayed, and how
+// fast.
+type replayOptions struct {
+	// Start is the index of the first data frame sent from each pass.
+	Start int64
+	// Count, if nonzero, limits the data frames sent from each pass.
+	Count int64
+	// Loops gives the number of passes over the file, or 0 for no limit.
+	Loops int
+	// Rate, if nonzero, limits the data frames sent per second.
+	Rate float64
+}
+
+// A pacer spaces out events to a fixed rate.
+type pacer struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func newPacer(rate float64) *pacer {
+	p := &pacer{}
+	if rate > 0 {
+		p.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return p
+}
+
+// wait returns when the next event is due, calling idle first if it
+// would otherwise block.
+func (p *pacer) wait(ctx context.Context, idle func() error) error {
+	if p.interval == 0 {
+		return nil
+	}
+	now := time.Now()
+	if p.next.After(now) {
+		if err := idle(); err != nil {
+			return err
+		}
+		t := time.NewTimer(p.next.Sub(now))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	} else {
+		p.next = now
+	}
+	p.next = p.next.Add(p.interval)
+	return nil
+}
+
+// replay writes the data frames of the named file to w, and returns the
+// number of data frames written.
+func replay(ctx context.Context, w *framestream.Writer, fname string, opt replayOptions) (sent int64, err error) {
+	p := newPacer(opt.Rate)
+	for pass := 0; opt.Loops == 0 || pass < opt.Loops; pass++ {
+		n, err := replayFile(ctx, w, fname, opt, p)
+		sent += n
+		if err != nil {
+			return sent, err
+		}
+		if n == 0 {
+			// Nothing to send; don't spin.
+			break
+		}
+	}
+	return sent, nil
+}
+
+func replayFile(ctx context.Context, w *framestream.Writer, fname string, opt replayOptions, p *pacer) (sent int64, err error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	r, err := framestream.NewReader(file, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fname, err)
+	}
+	for index := int64(0); opt.Count == 0 || sent < opt.Count; index++ {
+		frame, err := r.Next()
+		if err == framestream.EOF {
+			break
+		}
+		if errors.Is(err, framestream.ErrDataFrameTooLarge) {
+			log.Printf("%s: %v", fname, err)
+			continue
+		}
+		if err != nil {
+			return sent, fmt.Errorf("%s: %w", fname, err)
+		}
+		if index < opt.Start {
+			continue
+		}
+		if err := p.wait(ctx, w.Flush); err != nil {
+			return sent, err
+		}
+		if _, err := w.WriteFrameContext(ctx, frame); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// fileContentType returns the content type in the START frame of the named
+// file.
+func fileContentType(fname string) ([]byte, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r, err := framestream.NewReader(file, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	return r.ContentType(), nil
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s (-unix PATH | -tcp ADDR) [options] <INPUT FILE>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Sends the data frames of a Frame Streams file to a socket.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Arguments.
+	if (*unixPath == "") == (*tcpAddr == "") || flag.NArg() != 1 || *loops < 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	fname := flag.Arg(0)
+
+	ctype := []byte(*contentType)
+	if *contentType == "" {
+		var err error
+		if ctype, err = fileContentType(fname); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Connect.
+	var conn net.Conn
+	var err error
+	if *unixPath != "" {
+		conn, err = net.DialTimeout("unix", *unixPath, *timeout)
+	} else {
+		conn, err = net.DialTimeout("tcp", *tcpAddr, *timeout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	wopt := &framestream.WriterOptions{
+		Bidirectional: *bidirectional,
+		Timeout:       *timeout,
+	}
+	if len(ctype) > 0 {
+		wopt.ContentTypes = [][]byte{ctype}
+	}
+	w, err := framestream.NewWriter(conn, wopt)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	began := time.Now()
+	sent, err := replay(ctx, w, fname, replayOptions{
+		Start: *start,
+		Count: *count,
+		Loops: *loops,
+		Rate:  *rate,
+	})
+	if err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("sent %d frames in %v", sent, time.Since(began).Round(time.Millisecond))
+}
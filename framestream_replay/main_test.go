@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+func writeTestFile(t *testing.T, nframes int) string {
+	fname := filepath.Join(t.TempDir(), "test.fstrm")
+	file, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := framestream.NewWriter(file, &framestream.WriterOptions{
+		ContentTypes: [][]byte{[]byte("test")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < nframes; i++ {
+		if _, err := w.WriteFrame([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return fname
+}
+
+// testReplay replays fname to a local Server and returns the frames it
+// receives.
+func testReplay(t *testing.T, fname string, bidirectional bool, opt replayOptions) []string {
+	received := make(chan []string, 1)
+	s := &framestream.Server{
+		ReaderOptions: &framestream.ReaderOptions{
+			Bidirectional: bidirectional,
+			ContentTypes:  [][]byte{[]byte("test")},
+		},
+		Handler: framestream.HandlerFunc(func(ctx context.Context, r *framestream.Reader, conn net.Conn) {
+			var frames []string
+			for {
+				frame, err := r.Next()
+				if err != nil {
+					if err != framestream.EOF {
+						t.Error(err)
+					}
+					break
+				}
+				frames = append(frames, string(frame))
+			}
+			received <- frames
+		}),
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	w, err := framestream.NewWriter(conn, &framestream.WriterOptions{
+		Bidirectional: bidirectional,
+		ContentTypes:  [][]byte{[]byte("test")},
+		Timeout:       time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, err := replay(context.Background(), w, fname, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bidirectional {
+		conn.Close()
+	}
+
+	frames := <-received
+	if int64(len(frames)) != sent {
+		t.Errorf("sent %d frames, received %d", sent, len(frames))
+	}
+	return frames
+}
+
+func checkFrames(t *testing.T, frames []string, expected ...int) {
+	if len(frames) != len(expected) {
+		t.Fatalf("received %q, expected %v", frames, expected)
+	}
+	for i, e := range expected {
+		if frames[i] != strconv.Itoa(e) {
+			t.Fatalf("received %q, expected %v", frames, expected)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	fname := writeTestFile(t, 5)
+	for _, bidirectional := range []bool{true, false} {
+		frames := testReplay(t, fname, bidirectional, replayOptions{Loops: 1})
+		checkFrames(t, frames, 0, 1, 2, 3, 4)
+	}
+}
+
+func TestReplayStartCountLoop(t *testing.T) {
+	fname := writeTestFile(t, 5)
+	frames := testReplay(t, fname, true, replayOptions{Start: 1, Count: 3, Loops: 2})
+	checkFrames(t, frames, 1, 2, 3, 1, 2, 3)
+}
+
+func TestReplayRate(t *testing.T) {
+	fname := writeTestFile(t, 5)
+	began := time.Now()
+	frames := testReplay(t, fname, true, replayOptions{Loops: 1, Rate: 50})
+	checkFrames(t, frames, 0, 1, 2, 3, 4)
+	// Five frames at 50/s take at least four intervals of 20ms.
+	if d := time.Since(began); d < 80*time.Millisecond {
+		t.Errorf("replay took %v, expected at least 80ms", d)
+	}
+}
@@ -0,0 +1,285 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/bits"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/farsightsec/golang-framestream"
+)
+
+var jsonOutput = flag.Bool("json", false, "write the report as JSON")
+
+// percentiles are the frame size percentiles reported.
+var percentiles = []float64{50, 90, 99, 99.9}
+
+// A report summarizes the frames of one file.
+type report struct {
+	File        string            `json:"file"`
+	ContentType string            `json:"content_type"`
+	Streams     int               `json:"streams"`
+	Frames      int64             `json:"frames"`
+	Bytes       int64             `json:"bytes"`
+	MinSize     uint32            `json:"min_size"`
+	MaxSize     uint32            `json:"max_size"`
+	MeanSize    float64           `json:"mean_size"`
+	Percentiles map[string]uint32 `json:"percentiles,omitempty"`
+	Histogram   []bucket          `json:"histogram,omitempty"`
+	Seconds     float64           `json:"seconds"`
+	Problems    []string          `json:"problems,omitempty"`
+
+	// sizes counts the frames of each size, so that percentiles can be
+	// found in a single pass over files with any number of frames.
+	sizes map[uint32]int64
+}
+
+// A bucket counts the frames of sizes from Min to Max inclusive.
+type bucket struct {
+	Min   uint32 `json:"min"`
+	Max   uint32 `json:"max"`
+	Count int64  `json:"count"`
+}
+
+func (rep *report) problem(format string, args ...interface{}) {
+	rep.Problems = append(rep.Problems, fmt.Sprintf(format, args...))
+}
+
+func (rep *report) add(size uint32) {
+	if rep.Frames == 0 || size < rep.MinSize {
+		rep.MinSize = size
+	}
+	if size > rep.MaxSize {
+		rep.MaxSize = size
+	}
+	rep.Frames++
+	rep.Bytes += int64(size)
+	rep.sizes[size]++
+}
+
+// finish computes the mean, percentiles and histogram from the frame
+// sizes counted.
+func (rep *report) finish() {
+	rep.Percentiles = make(map[string]uint32)
+	if rep.Frames == 0 {
+		return
+	}
+	rep.MeanSize = float64(rep.Bytes) / float64(rep.Frames)
+
+	sizes := make([]uint32, 0, len(rep.sizes))
+	for size := range rep.sizes {
+		sizes = append(sizes, size)
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+
+	// Nearest-rank percentiles.
+	var seen int64
+	p := 0
+	for _, size := range sizes {
+		seen += rep.sizes[size]
+		for ; p < len(percentiles); p++ {
+			rank := int64(math.Ceil(percentiles[p] / 100 * float64(rep.Frames)))
+			if rank > seen {
+				break
+			}
+			rep.Percentiles[percentileName(percentiles[p])] = size
+		}
+	}
+
+	// Buckets of sizes 0, 1, 2-3, 4-7 and so on, from the smallest to the
+	// largest occupied.
+	counts := make([]int64, 33)
+	for _, size := range sizes {
+		counts[bits.Len32(size)] += rep.sizes[size]
+	}
+	lo, hi := bits.Len32(rep.MinSize), bits.Len32(rep.MaxSize)
+	for i := lo; i <= hi; i++ {
+		b := bucket{Count: counts[i]}
+		if i > 0 {
+			b.Min = 1 << (i - 1)
+			b.Max = b.Min<<1 - 1
+		}
+		rep.Histogram = append(rep.Histogram, b)
+	}
+}
+
+func percentileName(p float64) string {
+	return fmt.Sprintf("p%g", p)
+}
+
+// analyze reads a Frame Streams file in one pass and reports on its frames.
+// The file may hold several streams one after another.
+func analyze(name string, r io.Reader) *report {
+	rep := &report{File: name, sizes: make(map[uint32]int64)}
+	began := time.Now()
+	defer func() {
+		rep.Seconds = time.Since(began).Seconds()
+		rep.finish()
+	}()
+
+	m, err := framestream.NewMultiStreamReader(r, &framestream.MultiStreamReaderOptions{
+		OnStream: func(stream int, ctype []byte) { rep.Streams = stream + 1 },
+	})
+	if err != nil {
+		rep.problem("handshake: %v", err)
+		return rep
+	}
+	rep.ContentType = string(m.ContentType())
+
+	// Each frame is counted once the Reader has moved past it, so that a
+	// truncated frame is not.
+	pending := false
+	var size uint32
+	for {
+		next, _, err := m.NextFrameReader()
+		if err != nil && err != framestream.EOF {
+			var perr *framestream.ProtocolError
+			if errors.As(err, &perr) && perr.Phase == framestream.PhaseHandshake {
+				// What follows the last STOP frame is not a stream,
+				// so the frame before it was complete.
+				if pending {
+					rep.add(size)
+				}
+				rep.problem("trailing data after stream %d: %v", rep.Streams, err)
+			} else {
+				rep.problem("%v", err)
+			}
+			return rep
+		}
+		if pending {
+			rep.add(size)
+		}
+		if err == framestream.EOF {
+			break
+		}
+		pending, size = true, next
+	}
+	if !m.Stopped() {
+		rep.problem("missing STOP frame")
+	}
+	return rep
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-json] <INPUT FILE>...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Reports statistics on the frames of FrameStreams formatted input files.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Arguments.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	var reports []*report
+	failed := false
+	for _, name := range flag.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rep := analyze(name, file)
+		file.Close()
+		if len(rep.Problems) > 0 {
+			failed = true
+		}
+		if *jsonOutput {
+			reports = append(reports, rep)
+			continue
+		}
+		if err := printReport(out, rep); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *jsonOutput {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := out.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if failed {
+		os.Exit(2)
+	}
+}
+
+const barWidth = 40
+
+func printReport(w io.Writer, rep *report) error {
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "%s:\n", rep.File)
+	fmt.Fprintf(b, "  Content type: %q\n", rep.ContentType)
+	if rep.Streams > 1 {
+		fmt.Fprintf(b, "  Streams:      %d\n", rep.Streams)
+	}
+	fmt.Fprintf(b, "  Frames:       %d\n", rep.Frames)
+	fmt.Fprintf(b, "  Bytes:        %d\n", rep.Bytes)
+	if rep.Frames > 0 {
+		fmt.Fprintf(b, "  Frame size:   min %d, mean %.1f, max %d\n",
+			rep.MinSize, rep.MeanSize, rep.MaxSize)
+		fmt.Fprintf(b, "  Percentiles: ")
+		for _, p := range percentiles {
+			name := percentileName(p)
+			fmt.Fprintf(b, " %s %d", name, rep.Percentiles[name])
+		}
+		fmt.Fprintf(b, "\n")
+	}
+	if rep.Seconds > 0 {
+		fmt.Fprintf(b, "  Read in:      %.3fs (%.0f frames/s, %.1f MB/s)\n", rep.Seconds,
+			float64(rep.Frames)/rep.Seconds, float64(rep.Bytes)/rep.Seconds/1e6)
+	}
+	if len(rep.Histogram) > 0 {
+		var most int64
+		for _, bk := range rep.Histogram {
+			if bk.Count > most {
+				most = bk.Count
+			}
+		}
+		fmt.Fprintf(b, "  Frame sizes:\n")
+		for _, bk := range rep.Histogram {
+			bar := int(bk.Count * barWidth / most)
+			if bar == 0 && bk.Count > 0 {
+				bar = 1
+			}
+			fmt.Fprintf(b, "    %10d - %-10d %12d %s\n",
+				bk.Min, bk.Max, bk.Count, strings.Repeat("#", bar))
+		}
+	}
+	for _, p := range rep.Problems {
+		fmt.Fprintf(b, "  Problem:      %s\n", p)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
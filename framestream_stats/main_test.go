@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+func statsStream(t *testing.T, sizes ...int) []byte {
+	buf := new(bytes.Buffer)
+	w, err := framestream.NewWriter(buf, &framestream.WriterOptions{
+		ContentTypes: [][]byte{[]byte("test")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range sizes {
+		if _, err := w.WriteFrame(make([]byte, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAnalyze(t *testing.T) {
+	var sizes []int
+	for i := 1; i <= 100; i++ {
+		sizes = append(sizes, i)
+	}
+	rep := analyze("test", bytes.NewReader(statsStream(t, sizes...)))
+	if len(rep.Problems) != 0 {
+		t.Errorf("problems: %q", rep.Problems)
+	}
+	if rep.ContentType != "test" || rep.Streams != 1 || rep.Frames != 100 || rep.Bytes != 5050 {
+		t.Errorf("content type %q, %d streams, %d frames, %d bytes",
+			rep.ContentType, rep.Streams, rep.Frames, rep.Bytes)
+	}
+	if rep.MinSize != 1 || rep.MaxSize != 100 || rep.MeanSize != 50.5 {
+		t.Errorf("sizes min %d, mean %v, max %d", rep.MinSize, rep.MeanSize, rep.MaxSize)
+	}
+	for name, expected := range map[string]uint32{"p50": 50, "p90": 90, "p99": 99, "p99.9": 100} {
+		if rep.Percentiles[name] != expected {
+			t.Errorf("%s = %d, expected %d", name, rep.Percentiles[name], expected)
+		}
+	}
+
+	// Buckets 1, 2-3, 4-7, ..., 64-127.
+	if len(rep.Histogram) != 7 {
+		t.Fatalf("histogram %v", rep.Histogram)
+	}
+	last := rep.Histogram[6]
+	if last.Min != 64 || last.Max != 127 || last.Count != 37 {
+		t.Errorf("last bucket %+v", last)
+	}
+	var total int64
+	for _, b := range rep.Histogram {
+		total += b.Count
+	}
+	if total != 100 {
+		t.Errorf("histogram counts %d frames", total)
+	}
+}
+
+func TestAnalyzeProblems(t *testing.T) {
+	stream := statsStream(t, 10, 20, 30)
+
+	// Without the STOP frame.
+	rep := analyze("test", bytes.NewReader(stream[:len(stream)-12]))
+	if rep.Frames != 3 || len(rep.Problems) != 1 || rep.Problems[0] != "missing STOP frame" {
+		t.Errorf("%d frames, problems %q", rep.Frames, rep.Problems)
+	}
+
+	// Truncated within the last frame, which is not counted.
+	rep = analyze("test", bytes.NewReader(stream[:len(stream)-20]))
+	if rep.Frames != 2 || rep.Bytes != 30 || len(rep.Problems) != 1 {
+		t.Errorf("%d frames, %d bytes, problems %q", rep.Frames, rep.Bytes, rep.Problems)
+	}
+}
+
+func TestAnalyzeStreams(t *testing.T) {
+	stream := statsStream(t, 10, 20, 30)
+
+	// Concatenated streams are all counted.
+	file := append(append([]byte(nil), stream...), statsStream(t, 40)...)
+	rep := analyze("test", bytes.NewReader(file))
+	if rep.Streams != 2 || rep.Frames != 4 || rep.Bytes != 100 || len(rep.Problems) != 0 {
+		t.Errorf("%d streams, %d frames, %d bytes, problems %q",
+			rep.Streams, rep.Frames, rep.Bytes, rep.Problems)
+	}
+
+	// Data following the STOP frame which is not a stream is reported.
+	file = append(append([]byte(nil), stream...), "trailing garbage"...)
+	rep = analyze("test", bytes.NewReader(file))
+	if rep.Streams != 1 || rep.Frames != 3 || len(rep.Problems) != 1 ||
+		!strings.HasPrefix(rep.Problems[0], "trailing data after stream 1") {
+		t.Errorf("%d streams, %d frames, problems %q", rep.Streams, rep.Frames, rep.Problems)
+	}
+}
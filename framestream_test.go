@@ -2,7 +2,10 @@ package framestream_test
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"sync"
 	"testing"
@@ -67,7 +70,8 @@ func TestBidirectional(t *testing.T) {
 			})
 
 		if err != nil {
-			t.Fatal(err)
+			t.Error(err)
+			return
 		}
 		testDecoder(t, dec, 9)
 	}()
@@ -270,3 +274,242 @@ func TestContentTypes(t *testing.T) {
 		contentTypes("type4", "type3", "type2"),
 		[]byte("type2"), true)
 }
+
+func testWriteFrames(t *testing.T, w io.Writer, r io.Reader, size int) {
+	frames := make([][]byte, 8)
+	for i := range frames {
+		frames[i] = bytes.Repeat([]byte{byte(i)}, size+i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fr, err := framestream.NewReader(r, &framestream.ReaderOptions{
+			MaxFrameSize: uint32(4 * size),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := append(frames, bytes.Join(frames[:3], nil))
+		for i, f := range expected {
+			got, err := fr.Next()
+			if err != nil {
+				t.Errorf("frame %d: %v", i, err)
+				return
+			}
+			if !bytes.Equal(got, f) {
+				t.Errorf("frame %d: received %d bytes, expected %d", i, len(got), len(f))
+			}
+		}
+		if _, err := fr.Next(); err != framestream.EOF {
+			t.Errorf("Next returned %v, expected EOF", err)
+		}
+	}()
+
+	fw, err := framestream.NewWriter(w, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.WriteFrames(frames); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.WriteFrameV(frames[:3]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
+
+func TestWriteFrames(t *testing.T) {
+	r, w := io.Pipe()
+	testWriteFrames(t, w, r, 100)
+}
+
+func TestWriteFramesConn(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Large frames are sent with a vectored write.
+	testWriteFrames(t, c, s, 16384)
+}
+
+// Writer benchmarks count one operation per frame, so that allocs/op gives
+// the allocations per frame written.
+
+const benchBatchSize = 64
+
+func benchmarkWriter(b *testing.B, w io.Writer, size int, write func(*framestream.Writer, [][]byte)) {
+	fw, err := framestream.NewWriter(w, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	frames := make([][]byte, benchBatchSize)
+	for i := range frames {
+		frames[i] = make([]byte, size)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i += benchBatchSize {
+		if n := b.N - i; n < benchBatchSize {
+			frames = frames[:n]
+		}
+		write(fw, frames)
+	}
+	fw.Flush()
+}
+
+func writeFrameLoop(w *framestream.Writer, frames [][]byte) {
+	for _, f := range frames {
+		w.WriteFrame(f)
+	}
+}
+
+func writeFrames(w *framestream.Writer, frames [][]byte) {
+	w.WriteFrames(frames)
+}
+
+func writeFrameV(w *framestream.Writer, frames [][]byte) {
+	for _, f := range frames {
+		w.WriteFrameV(f[:len(f)/2], f[len(f)/2:])
+	}
+}
+
+// benchConn returns a TCP connection whose peer discards all input.
+func benchConn(b *testing.B) net.Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	go func() {
+		defer l.Close()
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		io.Copy(ioutil.Discard, c)
+		c.Close()
+	}()
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { c.Close() })
+	return c
+}
+
+func BenchmarkWriteFrame(b *testing.B) {
+	benchmarkWriter(b, ioutil.Discard, 128, writeFrameLoop)
+}
+
+func BenchmarkWriteFrames(b *testing.B) {
+	benchmarkWriter(b, ioutil.Discard, 128, writeFrames)
+}
+
+func BenchmarkWriteFrameV(b *testing.B) {
+	benchmarkWriter(b, ioutil.Discard, 128, writeFrameV)
+}
+
+func BenchmarkWriteFrameConn(b *testing.B) {
+	benchmarkWriter(b, benchConn(b), 128, writeFrameLoop)
+}
+
+func BenchmarkWriteFramesConn(b *testing.B) {
+	benchmarkWriter(b, benchConn(b), 128, writeFrames)
+}
+
+func BenchmarkWriteFrameConnLarge(b *testing.B) {
+	benchmarkWriter(b, benchConn(b), 16384, writeFrameLoop)
+}
+
+func BenchmarkWriteFramesConnLarge(b *testing.B) {
+	benchmarkWriter(b, benchConn(b), 16384, writeFrames)
+}
+
+// cycleReader supplies head, then repeats body forever.
+type cycleReader struct {
+	head, body []byte
+	off        int
+}
+
+func (c *cycleReader) Read(b []byte) (n int, err error) {
+	if len(c.head) > 0 {
+		n = copy(b, c.head)
+		c.head = c.head[n:]
+		return
+	}
+	for n < len(b) {
+		m := copy(b[n:], c.body[c.off:])
+		n += m
+		c.off = (c.off + m) % len(c.body)
+	}
+	return
+}
+
+// benchmarkReader reads one frame per operation from an endless stream
+// repeating the given frames. A nil frame stands for a control frame.
+func benchmarkReader(b *testing.B, frames [][]byte) {
+	start := new(bytes.Buffer)
+	cf := framestream.ControlStart
+	cf.SetContentType([]byte("test"))
+	cf.Encode(start)
+
+	body := new(bytes.Buffer)
+	size, ndata := 0, 0
+	for _, f := range frames {
+		if f == nil {
+			ready := framestream.ControlReady
+			ready.SetContentTypes(contentTypes("type1", "type2"))
+			ready.Encode(body)
+			continue
+		}
+		binary.Write(body, binary.BigEndian, uint32(len(f)))
+		body.Write(f)
+		size += len(f)
+		ndata++
+	}
+
+	r, err := framestream.NewReader(&cycleReader{head: start.Bytes(), body: body.Bytes()},
+		&framestream.ReaderOptions{ContentTypes: contentTypes("test")})
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, 65536)
+	b.ReportAllocs()
+	b.SetBytes(int64(size / ndata))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.ReadFrame(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadFrameSmall(b *testing.B) {
+	benchmarkReader(b, [][]byte{make([]byte, 64)})
+}
+
+func BenchmarkReadFrameLarge(b *testing.B) {
+	benchmarkReader(b, [][]byte{make([]byte, 65536)})
+}
+
+func BenchmarkReadFrameControl(b *testing.B) {
+	// Each data frame is preceded by three control frames.
+	benchmarkReader(b, [][]byte{nil, nil, nil, make([]byte, 64)})
+}
@@ -0,0 +1,222 @@
+package framestream_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+// fuzzControlFrames returns encoded control frames for seeding, without
+// their escape sequence.
+func fuzzControlFrames(f *testing.F) [][]byte {
+	var frames [][]byte
+	for _, cf := range []framestream.ControlFrame{
+		framestream.ControlStart,
+		framestream.ControlStop,
+		framestream.ControlReady,
+		framestream.ControlAccept,
+		framestream.ControlFinish,
+		{ControlType: framestream.CONTROL_READY, ContentTypes: contentTypes("type1", "type2", "type3")},
+		{ControlType: framestream.CONTROL_START, ContentTypes: contentTypes("test")},
+		{ControlType: framestream.CONTROL_START, ContentTypes: contentTypes("")},
+		{ControlType: framestream.CONTROL_START, Fields: []framestream.ControlField{{Type: 0x7f00, Value: []byte("x")}}},
+	} {
+		buf := new(bytes.Buffer)
+		if err := cf.Encode(buf); err != nil {
+			f.Fatal(err)
+		}
+		frames = append(frames, buf.Bytes()[4:])
+	}
+	return frames
+}
+
+// fuzzStreams returns encoded unidirectional streams for seeding.
+func fuzzStreams(f *testing.F) [][]byte {
+	var streams [][]byte
+	for _, sizes := range [][]int{{}, {1}, {1, 2, 3, 4, 5, 6, 7, 8, 9}, {15}} {
+		streams = append(streams, testStream(f, "", sizes...).Bytes())
+	}
+	buf := new(bytes.Buffer)
+	enc, _ := framestream.NewEncoder(buf, &framestream.EncoderOptions{ContentType: []byte("test")})
+	enc.Write([]byte("hello, world"))
+	enc.Close()
+	return append(streams, buf.Bytes())
+}
+
+// emptyLastFieldFrames are START frames, without their escape, whose last
+// field is a zero-length field taking exactly 8 bytes, which Decode once
+// rejected as trailing garbage.
+var emptyLastFieldFrames = [][]byte{
+	{0, 0, 0, 12, 0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 0},
+	{0, 0, 0, 20, 0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0x7f, 0, 0, 0, 0, 0},
+}
+
+func TestControlFrameDecodeEmptyLastField(t *testing.T) {
+	for i, b := range emptyLastFieldFrames {
+		var cf framestream.ControlFrame
+		if err := cf.Decode(bytes.NewReader(b)); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if len(cf.ContentTypes) != 1 || len(cf.ContentTypes[0]) != 0 {
+			t.Errorf("frame %d: content types %q", i, cf.ContentTypes)
+		}
+		if nfields := len(cf.Fields); nfields != i {
+			t.Errorf("frame %d: %d fields, expected %d", i, nfields, i)
+		} else if i == 1 && (cf.Fields[0].Type != 0x7f00 || len(cf.Fields[0].Value) != 0) {
+			t.Errorf("frame %d: field %+v", i, cf.Fields[0])
+		}
+	}
+}
+
+func FuzzControlFrameDecode(f *testing.F) {
+	for _, b := range fuzzControlFrames(f) {
+		f.Add(b)
+	}
+	f.Add([]byte{0, 0, 0, 12, 0, 0, 0, 2, 0, 0, 0, 1})
+	f.Add([]byte{0, 0, 0, 8, 0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 0})
+	for _, b := range emptyLastFieldFrames {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var cf framestream.ControlFrame
+		if err := cf.Decode(bytes.NewReader(b)); err != nil {
+			return
+		}
+
+		// Anything decoded re-encodes to an equivalent frame.
+		buf := new(bytes.Buffer)
+		if err := cf.Encode(buf); err != nil {
+			t.Fatalf("Encode(%+v): %v", cf, err)
+		}
+		var dec framestream.ControlFrame
+		if err := dec.DecodeEscape(buf); err != nil {
+			t.Fatalf("Decode of re-encoded %+v: %v", cf, err)
+		}
+		if !equalControlFrames(&cf, &dec) {
+			t.Fatalf("round trip changed %+v to %+v", cf, dec)
+		}
+	})
+}
+
+func FuzzControlFrameRoundTrip(f *testing.F) {
+	f.Add(uint32(framestream.CONTROL_READY), []byte("type1"), []byte("type2"), uint32(0x7f00), []byte("value"))
+	f.Add(uint32(framestream.CONTROL_START), []byte(""), []byte{}, uint32(0), []byte{})
+	f.Fuzz(func(t *testing.T, ctype uint32, t1, t2 []byte, ftype uint32, fvalue []byte) {
+		cf := framestream.ControlFrame{
+			ControlType:  ctype,
+			ContentTypes: [][]byte{t1, t2},
+		}
+		if ftype != framestream.CONTROL_FIELD_CONTENT_TYPE {
+			cf.Fields = []framestream.ControlField{{Type: ftype, Value: fvalue}}
+		}
+
+		buf := new(bytes.Buffer)
+		if err := cf.Encode(buf); err != nil {
+			if !errors.Is(err, framestream.ErrControlFrameTooLarge) {
+				t.Fatalf("Encode: %v", err)
+			}
+			return
+		}
+		var dec framestream.ControlFrame
+		if err := dec.DecodeEscape(buf); err != nil {
+			t.Fatalf("Decode of %+v: %v", cf, err)
+		}
+		if !equalControlFrames(&cf, &dec) {
+			t.Fatalf("round trip changed %+v to %+v", cf, dec)
+		}
+		if buf.Len() != 0 {
+			t.Fatalf("%d bytes left after Decode", buf.Len())
+		}
+	})
+}
+
+func equalControlFrames(a, b *framestream.ControlFrame) bool {
+	if a.ControlType != b.ControlType ||
+		len(a.ContentTypes) != len(b.ContentTypes) ||
+		len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for i := range a.ContentTypes {
+		if !bytes.Equal(a.ContentTypes[i], b.ContentTypes[i]) {
+			return false
+		}
+	}
+	for i := range a.Fields {
+		if a.Fields[i].Type != b.Fields[i].Type ||
+			!bytes.Equal(a.Fields[i].Value, b.Fields[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzReadStream reads all frames of a stream, checking that the Reader
+// neither panics nor returns more data than the stream holds.
+func fuzzReadStream(t *testing.T, r io.Reader, size int, opt *framestream.ReaderOptions) {
+	fr, err := framestream.NewReader(r, opt)
+	if err != nil {
+		return
+	}
+	buf := make([]byte, 64)
+	total := 0
+	for {
+		n, err := fr.ReadFrame(buf)
+		if err != nil && !errors.Is(err, framestream.ErrDataFrameTooLarge) {
+			return
+		}
+		total += n
+		if total > size {
+			t.Fatalf("read %d bytes of frames from %d byte stream", total, size)
+		}
+	}
+}
+
+func FuzzReader(f *testing.F) {
+	for _, b := range fuzzStreams(f) {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		fuzzReadStream(t, bytes.NewReader(b), len(b), nil)
+		fuzzReadStream(t, bytes.NewReader(b), len(b), &framestream.ReaderOptions{
+			ContentTypes: contentTypes("test"),
+		})
+		var skipped int64
+		fuzzReadStream(t, bytes.NewReader(b), len(b), &framestream.ReaderOptions{
+			MaxFrameSize: 64,
+			Recover:      true,
+			OnSkip: func(offset, length int64) {
+				skipped += length
+				if offset < 0 || length <= 0 || offset+length > int64(len(b)) {
+					t.Fatalf("skipped %d bytes at offset %d of %d byte stream", length, offset, len(b))
+				}
+			},
+		})
+		if skipped > int64(len(b)) {
+			t.Fatalf("skipped %d bytes of %d byte stream", skipped, len(b))
+		}
+	})
+}
+
+func FuzzReaderBidirectional(f *testing.F) {
+	ready := new(bytes.Buffer)
+	cf := framestream.ControlReady
+	cf.SetContentTypes(contentTypes("test"))
+	cf.Encode(ready)
+	for _, b := range fuzzStreams(f) {
+		f.Add(append(append([]byte(nil), ready.Bytes()...), b...))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		rw := struct {
+			io.Reader
+			io.Writer
+		}{bytes.NewReader(b), ioutil.Discard}
+		fuzzReadStream(t, rw, len(b), &framestream.ReaderOptions{
+			Bidirectional: true,
+			ContentTypes:  contentTypes("test"),
+		})
+	})
+}
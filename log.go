@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// Readers and Writers log control frames at debug level, and protocol
+// failures at warn level, with these attributes:
+//
+//	role           "reader" or "writer"
+//	control_type   the control frame type, such as "START"
+//	direction      "sent" or "received"
+//	content_types  the content types of the control frame, if any
+//	offset         the offset in the received stream of the frame
+//	phase          the phase of the session in which an error occurred
+//	length         the length of a data frame
+//	max_frame_size the largest data frame accepted
+//	error          the error
+func newLogger(l *slog.Logger, role string) *slog.Logger {
+	if l == nil {
+		return nil
+	}
+	return l.With(slog.String("role", role))
+}
+
+// logControl logs a control frame sent or received. The offset is only
+// logged for received frames.
+func logControl(l *slog.Logger, cf *ControlFrame, dir Direction, offset int64) {
+	if l == nil || !l.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	attrs := make([]slog.Attr, 0, 4)
+	attrs = append(attrs,
+		slog.String("control_type", ControlTypeName(cf.ControlType)),
+		slog.String("direction", dir.String()))
+	if len(cf.ContentTypes) > 0 {
+		ctypes := make([]string, len(cf.ContentTypes))
+		for i, t := range cf.ContentTypes {
+			ctypes[i] = string(t)
+		}
+		attrs = append(attrs, slog.Any("content_types", ctypes))
+	}
+	if dir == DirectionReceived {
+		attrs = append(attrs, slog.Int64("offset", offset))
+	}
+	l.LogAttrs(context.Background(), slog.LevelDebug, "control frame", attrs...)
+}
+
+// logWarn logs a protocol failure, with the phase and offset of err if it
+// is a *ProtocolError.
+func logWarn(l *slog.Logger, msg string, err error, attrs ...slog.Attr) {
+	if l == nil {
+		return
+	}
+	var perr *ProtocolError
+	if errors.As(err, &perr) {
+		attrs = append(attrs,
+			slog.String("phase", perr.Phase.String()),
+			slog.Int64("offset", perr.Offset))
+		if perr.Length != 0 {
+			attrs = append(attrs, slog.Uint64("length", uint64(perr.Length)))
+		}
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	l.LogAttrs(context.Background(), slog.LevelWarn, msg, attrs...)
+}
@@ -0,0 +1,193 @@
+package framestream_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+type logRecords struct {
+	buf bytes.Buffer
+}
+
+func (lr *logRecords) logger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(&lr.buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func (lr *logRecords) records(t *testing.T) []map[string]interface{} {
+	var records []map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(lr.buf.Bytes()))
+	for dec.More() {
+		var rec map[string]interface{}
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+// controlRecords returns "TYPE direction" for each control frame record,
+// checking that each has the given role.
+func controlRecords(t *testing.T, records []map[string]interface{}, role string) []string {
+	var frames []string
+	for _, rec := range records {
+		if rec["msg"] != "control frame" {
+			continue
+		}
+		if rec["level"] != "DEBUG" || rec["role"] != role {
+			t.Errorf("record %v", rec)
+		}
+		if rec["direction"] == "received" && rec["offset"] == nil {
+			t.Errorf("received frame record without offset: %v", rec)
+		}
+		frames = append(frames, rec["control_type"].(string)+" "+rec["direction"].(string))
+	}
+	return frames
+}
+
+func TestLogControlFrames(t *testing.T) {
+	var wlog, rlog logRecords
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer client.Close()
+		w, err := framestream.NewWriter(client, &framestream.WriterOptions{
+			Bidirectional: true,
+			ContentTypes:  contentTypes("test"),
+			Logger:        wlog.logger(),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.WriteFrame([]byte("frame"))
+		if err := w.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	r, err := framestream.NewReader(server, &framestream.ReaderOptions{
+		Bidirectional: true,
+		ContentTypes:  contentTypes("test"),
+		Logger:        rlog.logger(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := r.Next(); err != nil {
+			break
+		}
+	}
+	<-done
+
+	wrecs := wlog.records(t)
+	checkStrings(t, "Writer control frames", controlRecords(t, wrecs, "writer"),
+		[]string{"READY sent", "ACCEPT received", "START sent", "STOP sent", "FINISH received"})
+	if ctypes, ok := wrecs[0]["content_types"].([]interface{}); !ok || len(ctypes) != 1 || ctypes[0] != "test" {
+		t.Errorf("READY record %v", wrecs[0])
+	}
+	checkStrings(t, "Reader control frames", controlRecords(t, rlog.records(t), "reader"),
+		[]string{"READY received", "ACCEPT sent", "START received", "STOP received", "FINISH sent"})
+}
+
+// findRecord returns the first record with the given message.
+func findRecord(t *testing.T, lr *logRecords, msg string) map[string]interface{} {
+	t.Helper()
+	for _, rec := range lr.records(t) {
+		if rec["msg"] == msg {
+			return rec
+		}
+	}
+	t.Fatalf("no %q record", msg)
+	return nil
+}
+
+func TestLogNegotiationFailure(t *testing.T) {
+	var rlog logRecords
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		framestream.NewWriter(client, &framestream.WriterOptions{
+			Bidirectional: true,
+			ContentTypes:  contentTypes("other"),
+		})
+	}()
+	_, err := framestream.NewReader(server, &framestream.ReaderOptions{
+		Bidirectional: true,
+		ContentTypes:  contentTypes("test"),
+		Logger:        rlog.logger(),
+	})
+	server.Close()
+	if !errors.Is(err, framestream.ErrContentTypeMismatch) {
+		t.Fatalf("expected ErrContentTypeMismatch, received %v", err)
+	}
+	rec := findRecord(t, &rlog, "handshake failed")
+	if rec["level"] != "WARN" || rec["role"] != "reader" || rec["phase"] != "handshake" || rec["error"] == nil {
+		t.Errorf("record %v", rec)
+	}
+}
+
+func TestLogDataErrors(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w, err := framestream.NewWriter(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteFrame(make([]byte, 100))
+	w.WriteFrame(make([]byte, 10))
+	w.Flush()
+	buf.Truncate(buf.Len() - 5)
+
+	var rlog logRecords
+	r, err := framestream.NewReader(buf, &framestream.ReaderOptions{
+		MaxFrameSize: 50,
+		Logger:       rlog.logger(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); !errors.Is(err, framestream.ErrDataFrameTooLarge) {
+		t.Fatalf("expected ErrDataFrameTooLarge, received %v", err)
+	}
+	if _, err := r.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, received %v", err)
+	}
+
+	rec := findRecord(t, &rlog, "data frame too large")
+	if rec["level"] != "WARN" || rec["length"] != 100.0 || rec["max_frame_size"] != 50.0 || rec["offset"] == nil {
+		t.Errorf("record %v", rec)
+	}
+	rec = findRecord(t, &rlog, "stream truncated")
+	if rec["level"] != "WARN" || rec["length"] != 10.0 || rec["phase"] != "data" || rec["offset"] == nil {
+		t.Errorf("record %v", rec)
+	}
+}
+
+func TestLogRejectedControlFrame(t *testing.T) {
+	var rlog logRecords
+	_, err := framestream.NewReader(testStream(t, "test", 1), &framestream.ReaderOptions{
+		Logger: rlog.logger(),
+		OnControlFrame: func(dir framestream.Direction, cf *framestream.ControlFrame) error {
+			return errors.New("rejected")
+		},
+	})
+	if err == nil {
+		t.Fatal("rejected START frame accepted")
+	}
+	var warnings []string
+	for _, rec := range rlog.records(t) {
+		if rec["level"] == "WARN" {
+			warnings = append(warnings, rec["msg"].(string))
+		}
+	}
+	checkStrings(t, "warnings", warnings, []string{"handshake failed"})
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"expvar"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives measurements from a Reader or Writer. Implementations
+// must be safe for concurrent use if shared between Readers or Writers.
+// Separate Metrics should be given to Readers and Writers to count frames
+// read and written separately.
+type Metrics interface {
+	// DataFrame counts a data frame of the given size read or written.
+	DataFrame(size int)
+	// ControlFrame counts a control frame of the given type, sent to the
+	// peer if sent is true, and otherwise received.
+	ControlFrame(controlType uint32, sent bool)
+	// FrameTooLarge counts a data frame of the given size discarded with
+	// ErrDataFrameTooLarge, or skipped as damaged by a recovering Reader.
+	FrameTooLarge(size uint32)
+	// Handshake records the time taken by the handshake beginning a
+	// stream.
+	Handshake(d time.Duration)
+	// Flush records the time taken to flush buffered frames.
+	Flush(d time.Duration)
+}
+
+// ExpvarMetrics is a Metrics which publishes its counters and histograms
+// with the expvar package. Histograms count values in power-of-two
+// buckets, keyed by the largest value each holds; latencies are in
+// microseconds.
+type ExpvarMetrics struct {
+	m                  expvar.Map
+	frames, bytes      expvar.Int
+	tooLarge           expvar.Int
+	control            expvar.Map
+	frameSize          *histogram
+	tooLargeSize       *histogram
+	handshake, flushes *histogram
+}
+
+// NewExpvarMetrics returns an ExpvarMetrics, published under the given
+// name unless it is empty. Like expvar.Publish, it panics if the name is
+// already in use.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	em := &ExpvarMetrics{
+		frameSize:    new(histogram),
+		tooLargeSize: new(histogram),
+		handshake:    new(histogram),
+		flushes:      new(histogram),
+	}
+	em.m.Init()
+	em.control.Init()
+	em.m.Set("data_frames", &em.frames)
+	em.m.Set("data_bytes", &em.bytes)
+	em.m.Set("too_large_frames", &em.tooLarge)
+	em.m.Set("control_frames", &em.control)
+	em.m.Set("frame_size", em.frameSize)
+	em.m.Set("too_large_size", em.tooLargeSize)
+	em.m.Set("handshake_us", em.handshake)
+	em.m.Set("flush_us", em.flushes)
+	if name != "" {
+		expvar.Publish(name, &em.m)
+	}
+	return em
+}
+
+// Var returns the expvar.Var holding all of the metrics, for publishing
+// elsewhere.
+func (em *ExpvarMetrics) Var() expvar.Var {
+	return &em.m
+}
+
+func (em *ExpvarMetrics) DataFrame(size int) {
+	em.frames.Add(1)
+	em.bytes.Add(int64(size))
+	em.frameSize.observe(int64(size))
+}
+
+func (em *ExpvarMetrics) ControlFrame(controlType uint32, sent bool) {
+	key := ControlTypeName(controlType) + "_received"
+	if sent {
+		key = ControlTypeName(controlType) + "_sent"
+	}
+	em.control.Add(key, 1)
+}
+
+func (em *ExpvarMetrics) FrameTooLarge(size uint32) {
+	em.tooLarge.Add(1)
+	em.tooLargeSize.observe(int64(size))
+}
+
+func (em *ExpvarMetrics) Handshake(d time.Duration) {
+	em.handshake.observe(d.Microseconds())
+}
+
+func (em *ExpvarMetrics) Flush(d time.Duration) {
+	em.flushes.observe(d.Microseconds())
+}
+
+// histogram is an expvar.Var counting values in power-of-two buckets. It
+// is allocated separately to align its counters for atomic access.
+type histogram struct {
+	count, sum int64
+	buckets    [64]int64
+}
+
+func (h *histogram) observe(v int64) {
+	if v < 0 {
+		v = 0
+	}
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sum, v)
+	atomic.AddInt64(&h.buckets[bits.Len64(uint64(v))], 1)
+}
+
+// String returns the histogram as a JSON object, with the count of each
+// bucket keyed by the largest value it holds.
+func (h *histogram) String() string {
+	var b strings.Builder
+	b.WriteString(`{"count":`)
+	b.WriteString(strconv.FormatInt(atomic.LoadInt64(&h.count), 10))
+	b.WriteString(`,"sum":`)
+	b.WriteString(strconv.FormatInt(atomic.LoadInt64(&h.sum), 10))
+	b.WriteString(`,"buckets":{`)
+	first := true
+	for i := range h.buckets {
+		n := atomic.LoadInt64(&h.buckets[i])
+		if n == 0 {
+			continue
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		max := uint64(1)<<uint(i) - 1
+		b.WriteString(`"` + strconv.FormatUint(max, 10) + `":`)
+		b.WriteString(strconv.FormatInt(n, 10))
+	}
+	b.WriteString("}}")
+	return b.String()
+}
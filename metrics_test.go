@@ -0,0 +1,215 @@
+package framestream_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+// testMetrics records the measurements it receives.
+type testMetrics struct {
+	sync.Mutex
+	frames, bytes, tooLarge int
+	largest                 uint32
+	handshakes, flushes     int
+	control                 map[string]int
+}
+
+func newTestMetrics() *testMetrics {
+	return &testMetrics{control: make(map[string]int)}
+}
+
+func (m *testMetrics) DataFrame(size int) {
+	m.Lock()
+	defer m.Unlock()
+	m.frames++
+	m.bytes += size
+}
+
+func (m *testMetrics) ControlFrame(controlType uint32, sent bool) {
+	m.Lock()
+	defer m.Unlock()
+	key := framestream.ControlTypeName(controlType)
+	if sent {
+		key += " sent"
+	} else {
+		key += " received"
+	}
+	m.control[key]++
+}
+
+func (m *testMetrics) FrameTooLarge(size uint32) {
+	m.Lock()
+	defer m.Unlock()
+	m.tooLarge++
+	if size > m.largest {
+		m.largest = size
+	}
+}
+
+func (m *testMetrics) Handshake(d time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.handshakes++
+}
+
+func (m *testMetrics) Flush(d time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.flushes++
+}
+
+func checkControl(t *testing.T, who string, m *testMetrics, expected ...string) {
+	t.Helper()
+	if len(m.control) != len(expected) {
+		t.Errorf("%s control frames %v, expected %q", who, m.control, expected)
+		return
+	}
+	for _, key := range expected {
+		if m.control[key] != 1 {
+			t.Errorf("%s control frames %v, expected %q", who, m.control, expected)
+			return
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	client, server := net.Pipe()
+	wm, rm := newTestMetrics(), newTestMetrics()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer client.Close()
+		w, err := framestream.NewWriter(client, &framestream.WriterOptions{
+			Bidirectional: true,
+			ContentTypes:  contentTypes("test"),
+			Metrics:       wm,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.WriteFrame(make([]byte, 10))
+		w.WriteFrames([][]byte{make([]byte, 20), make([]byte, 30)})
+		w.WriteFrameV(make([]byte, 5), make([]byte, 5))
+		w.WriteFrame(make([]byte, 100))
+		if err := w.Flush(); err != nil {
+			t.Error(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	r, err := framestream.NewReader(server, &framestream.ReaderOptions{
+		Bidirectional: true,
+		ContentTypes:  contentTypes("test"),
+		MaxFrameSize:  50,
+		Metrics:       rm,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, err := r.Next()
+		if err == framestream.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, framestream.ErrDataFrameTooLarge) {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	if wm.frames != 5 || wm.bytes != 170 || wm.handshakes != 1 || wm.flushes != 1 {
+		t.Errorf("Writer counted %d frames, %d bytes, %d handshakes, %d flushes",
+			wm.frames, wm.bytes, wm.handshakes, wm.flushes)
+	}
+	checkControl(t, "Writer", wm, "READY sent", "ACCEPT received", "START sent", "STOP sent", "FINISH received")
+
+	if rm.frames != 4 || rm.bytes != 70 || rm.tooLarge != 1 || rm.largest != 100 || rm.handshakes != 1 {
+		t.Errorf("Reader counted %d frames, %d bytes, %d too large of up to %d bytes, %d handshakes",
+			rm.frames, rm.bytes, rm.tooLarge, rm.largest, rm.handshakes)
+	}
+	checkControl(t, "Reader", rm, "READY received", "ACCEPT sent", "START received", "STOP received", "FINISH sent")
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	em := framestream.NewExpvarMetrics("")
+	buf := new(bytes.Buffer)
+	w, err := framestream.NewWriter(buf, &framestream.WriterOptions{Metrics: em})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 1, 2, 3, 100} {
+		if _, err := w.WriteFrame(make([]byte, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		Frames    int64          `json:"data_frames"`
+		Bytes     int64          `json:"data_bytes"`
+		Control   map[string]int `json:"control_frames"`
+		FrameSize struct {
+			Count   int64            `json:"count"`
+			Sum     int64            `json:"sum"`
+			Buckets map[string]int64 `json:"buckets"`
+		} `json:"frame_size"`
+	}
+	if err := json.Unmarshal([]byte(em.Var().String()), &v); err != nil {
+		t.Fatalf("%v: %s", err, em.Var().String())
+	}
+	if v.Frames != 5 || v.Bytes != 106 || v.FrameSize.Count != 5 || v.FrameSize.Sum != 106 {
+		t.Errorf("metrics %s", em.Var().String())
+	}
+	expected := map[string]int64{"0": 1, "1": 1, "3": 2, "127": 1}
+	if len(v.FrameSize.Buckets) != len(expected) {
+		t.Errorf("frame size buckets %v, expected %v", v.FrameSize.Buckets, expected)
+	}
+	for k, n := range expected {
+		if v.FrameSize.Buckets[k] != n {
+			t.Errorf("frame size buckets %v, expected %v", v.FrameSize.Buckets, expected)
+		}
+	}
+	if v.Control["START_sent"] != 1 || v.Control["STOP_sent"] != 1 {
+		t.Errorf("control frames %v", v.Control)
+	}
+
+	// The sizes of frames too large are recorded.
+	rem := framestream.NewExpvarMetrics("")
+	r, err := framestream.NewReader(testStream(t, "", 10, 100), &framestream.ReaderOptions{
+		MaxFrameSize: 50,
+		Metrics:      rem,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := r.Next(); err == framestream.EOF {
+			break
+		}
+	}
+	var rv struct {
+		TooLarge     int64 `json:"too_large_frames"`
+		TooLargeSize struct {
+			Buckets map[string]int64 `json:"buckets"`
+		} `json:"too_large_size"`
+	}
+	if err := json.Unmarshal([]byte(rem.Var().String()), &rv); err != nil {
+		t.Fatalf("%v: %s", err, rem.Var().String())
+	}
+	if rv.TooLarge != 1 || len(rv.TooLargeSize.Buckets) != 1 || rv.TooLargeSize.Buckets["127"] != 1 {
+		t.Errorf("reader metrics %s", rem.Var().String())
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2026 by Farsight Security, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package framestream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// recoverBufferSize is the size of a recovering Reader's buffer, within
+// which a frame's length is checked against the frame following it.
+const recoverBufferSize = 64 * 1024
+
+// resync skips forward from the current position of a recovering Reader
+// to the next plausible frame boundary, reporting any bytes skipped.
+func (r *Reader) resync() error {
+	ok, err := r.plausible(false)
+	if ok || err != nil {
+		return err
+	}
+	if b := r.peek(4); len(b) == 4 && r.metrics != nil {
+		// An oversized data frame is skipped, but still counted.
+		if n := binary.BigEndian.Uint32(b); n > r.maxFrameSize {
+			r.metrics.FrameTooLarge(n)
+		}
+	}
+
+	start := r.pos.off
+	var skipped int64
+	for !ok {
+		r.r.Discard(1)
+		skipped++
+		if ok, err = r.plausible(true); err != nil {
+			return err
+		}
+	}
+	if r.onSkip != nil {
+		r.onSkip(start, skipped)
+	}
+	r.pos.off += skipped
+	return nil
+}
+
+// plausible reports whether a frame which is not obviously damaged begins
+// at the current position, or the stream ends there. A data frame must be
+// complete and, if scanning, followed by another plausible frame, in so far
+// as the buffer can hold them.
+func (r *Reader) plausible(scanning bool) (ok bool, err error) {
+	defer func() {
+		if err == nil {
+			err, r.peekErr = r.peekErr, nil
+		}
+	}()
+	if len(r.peek(4)) == 0 {
+		// At the end of the stream; let the caller find it.
+		return true, nil
+	}
+	n, ok := r.plausibleAt(0)
+	if !ok || n == 0 {
+		return ok, nil
+	}
+
+	// A data frame must be complete.
+	if !scanning {
+		return n > r.r.Size() || len(r.peek(n)) == n, nil
+	}
+	if n+4 > r.r.Size() {
+		return true, nil
+	}
+	b := r.peek(n + 4)
+	if len(b) < n {
+		return false, nil
+	}
+	if len(b) < n+4 {
+		// The stream ends after the frame.
+		return true, nil
+	}
+	_, ok = r.plausibleAt(n)
+	return ok, nil
+}
+
+// plausibleAt checks for a plausible frame at offset off from the current
+// position. If the frame is a data frame, plausibleAt returns the offset of
+// the frame following it, and otherwise returns zero.
+func (r *Reader) plausibleAt(off int) (next int, ok bool) {
+	b := r.peek(off + 8)
+	if len(b) < off+4 {
+		return 0, false
+	}
+	frameLen := binary.BigEndian.Uint32(b[off:])
+	if frameLen != 0 {
+		if frameLen > r.maxFrameSize {
+			return 0, false
+		}
+		return off + 4 + int(frameLen), true
+	}
+
+	// A control frame must decode.
+	if len(b) < off+8 {
+		return 0, false
+	}
+	cflen := binary.BigEndian.Uint32(b[off+4:])
+	if cflen < 4 || cflen > CONTROL_FRAME_LENGTH_MAX {
+		return 0, false
+	}
+	if off+8+int(cflen) > r.r.Size() {
+		return 0, true
+	}
+	b = r.peek(off + 8 + int(cflen))
+	if len(b) < off+8+int(cflen) {
+		return 0, false
+	}
+	if r.probe.Decode(bytes.NewReader(b[off+4:])) != nil {
+		return 0, false
+	}
+	return 0, true
+}
+
+// peek returns up to the next n bytes, which are fewer only at the end of
+// the stream or if an error is recorded in r.peekErr.
+func (r *Reader) peek(n int) []byte {
+	b, err := r.r.Peek(n)
+	if err != nil && err != io.EOF && r.peekErr == nil {
+		r.peekErr = err
+	}
+	return b
+}
@@ -0,0 +1,199 @@
+package framestream_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+
+	framestream "github.com/farsightsec/golang-framestream"
+)
+
+type skippedRegion struct{ offset, length int64 }
+
+// recoverTag marks the frame index at the start of each data frame, so
+// that it cannot be taken for a plausible frame length.
+const recoverTag = 0xa5000000
+
+// recoverStream returns an encoded stream of nframes data frames, each
+// beginning with its tagged index, and the offset of each frame.
+func recoverStream(t *testing.T, nframes int) ([]byte, []int64) {
+	buf := new(bytes.Buffer)
+	w, err := framestream.NewWriter(buf, &framestream.WriterOptions{
+		ContentTypes: contentTypes("test"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offsets []int64
+	for i := 0; i < nframes; i++ {
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, int64(buf.Len()))
+		frame := bytes.Repeat([]byte{'x'}, 4+i%50)
+		binary.BigEndian.PutUint32(frame, recoverTag|uint32(i))
+		if _, err := w.WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), offsets
+}
+
+// readRecovered reads a damaged stream in recovery mode, returning the
+// indexes of the frames read and the regions skipped.
+func readRecovered(t *testing.T, stream []byte) ([]int, []skippedRegion) {
+	var skipped []skippedRegion
+	r, err := framestream.NewReader(bytes.NewReader(stream), &framestream.ReaderOptions{
+		MaxFrameSize: 1024,
+		Recover:      true,
+		OnSkip: func(offset, length int64) {
+			skipped = append(skipped, skippedRegion{offset, length})
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frames []int
+	for {
+		frame, err := r.Next()
+		if err == framestream.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frame) >= 4 {
+			frames = append(frames, int(binary.BigEndian.Uint32(frame)&^recoverTag))
+		}
+	}
+	return frames, skipped
+}
+
+func checkRecovered(t *testing.T, frames []int, nframes int, lost ...int) {
+	var expected []int
+	for i := 0; i < nframes; i++ {
+		if len(lost) > 0 && lost[0] == i {
+			lost = lost[1:]
+			continue
+		}
+		expected = append(expected, i)
+	}
+	if len(frames) != len(expected) {
+		t.Fatalf("recovered frames %v, expected %v", frames, expected)
+	}
+	for i := range frames {
+		if frames[i] != expected[i] {
+			t.Fatalf("recovered frames %v, expected %v", frames, expected)
+		}
+	}
+}
+
+func TestRecoverLength(t *testing.T) {
+	stream, offsets := recoverStream(t, 100)
+	binary.BigEndian.PutUint32(stream[offsets[40]:], 0x7fffffff)
+
+	// Without recovery, the damaged length stops the Reader.
+	r, err := framestream.NewReader(bytes.NewReader(stream), &framestream.ReaderOptions{MaxFrameSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err = r.Next(); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, framestream.ErrDataFrameTooLarge) {
+		t.Errorf("without recovery: %v", err)
+	}
+
+	frames, skipped := readRecovered(t, stream)
+	checkRecovered(t, frames, 100, 40)
+	expected := skippedRegion{offsets[40], offsets[41] - offsets[40]}
+	if len(skipped) != 1 || skipped[0] != expected {
+		t.Errorf("skipped %v, expected %v", skipped, expected)
+	}
+}
+
+func TestRecoverLengthMetrics(t *testing.T) {
+	stream, offsets := recoverStream(t, 10)
+	binary.BigEndian.PutUint32(stream[offsets[4]:], 0x7fffffff)
+
+	m := newTestMetrics()
+	r, err := framestream.NewReader(bytes.NewReader(stream), &framestream.ReaderOptions{
+		MaxFrameSize: 1024,
+		Recover:      true,
+		Metrics:      m,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := r.Next(); err != nil {
+			if err != framestream.EOF {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	if m.frames != 9 || m.tooLarge != 1 || m.largest != 0x7fffffff {
+		t.Errorf("counted %d frames and %d too large of up to %d bytes",
+			m.frames, m.tooLarge, m.largest)
+	}
+}
+
+func TestRecoverInserted(t *testing.T) {
+	stream, offsets := recoverStream(t, 100)
+	garbage := bytes.Repeat([]byte{0xff}, 37)
+	damaged := append([]byte(nil), stream[:offsets[60]]...)
+	damaged = append(damaged, garbage...)
+	damaged = append(damaged, stream[offsets[60]:]...)
+
+	frames, skipped := readRecovered(t, damaged)
+	checkRecovered(t, frames, 100)
+	expected := skippedRegion{offsets[60], int64(len(garbage))}
+	if len(skipped) != 1 || skipped[0] != expected {
+		t.Errorf("skipped %v, expected %v", skipped, expected)
+	}
+}
+
+func TestRecoverOverwritten(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 20; trial++ {
+		stream, offsets := recoverStream(t, 100)
+		begin := offsets[30] + rnd.Int63n(offsets[31]-offsets[30])
+		end := offsets[35] + rnd.Int63n(offsets[36]-offsets[35])
+		rnd.Read(stream[begin:end])
+
+		frames, skipped := readRecovered(t, stream)
+		if len(skipped) == 0 {
+			t.Fatalf("trial %d: no region skipped", trial)
+		}
+		if skipped[0].offset < offsets[29] {
+			t.Errorf("trial %d: skipped %v before the damage at %d", trial, skipped, begin)
+		}
+		// Every frame after the damage is recovered, in order.
+		tail := frames[len(frames)-64:]
+		for i, f := range tail {
+			if f != 36+i {
+				t.Fatalf("trial %d: recovered frames %v", trial, frames)
+			}
+		}
+	}
+}
+
+func TestRecoverTruncated(t *testing.T) {
+	stream, offsets := recoverStream(t, 10)
+	stream = stream[:offsets[9]+6]
+
+	frames, skipped := readRecovered(t, stream)
+	checkRecovered(t, frames, 9)
+	expected := skippedRegion{offsets[9], 6}
+	if len(skipped) != 1 || skipped[0] != expected {
+		t.Errorf("skipped %v, expected %v", skipped, expected)
+	}
+}
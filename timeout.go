@@ -1,66 +1,153 @@
 package framestream
 
 import (
+	"context"
 	"io"
-	"net"
 	"time"
 )
 
+// deadlineConn is satisfied by net.Conn and *os.File.
+type deadlineConn interface {
+	io.ReadWriter
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// A timeoutConn applies the Timeout option and the contexts of the
+// *Context methods to a deadlineConn. With neither in use, it leaves the
+// connection's deadlines alone.
 type timeoutConn struct {
-	conn                      net.Conn
+	conn                      deadlineConn
 	readTimeout, writeTimeout time.Duration
+	ctx                       context.Context
+	// readSet and writeSet record that a deadline was set for ctx, and
+	// must be cleared when it is unbound.
+	readSet, writeSet bool
 }
 
 func (toc *timeoutConn) Write(b []byte) (int, error) {
-	if toc.writeTimeout != 0 {
-		toc.conn.SetWriteDeadline(time.Now().Add(toc.writeTimeout))
+	if err := toc.setDeadline(toc.conn.SetWriteDeadline, toc.writeTimeout, &toc.writeSet); err != nil {
+		return 0, err
 	}
 	return toc.conn.Write(b)
 }
 
 func (toc *timeoutConn) Read(b []byte) (int, error) {
-	if toc.readTimeout != 0 {
-		toc.conn.SetReadDeadline(time.Now().Add(toc.readTimeout))
+	if err := toc.setDeadline(toc.conn.SetReadDeadline, toc.readTimeout, &toc.readSet); err != nil {
+		return 0, err
 	}
 	return toc.conn.Read(b)
 }
 
-func timeoutWriter(w io.Writer, opt *WriterOptions) io.Writer {
-	if !opt.Bidirectional {
-		return w
+// setDeadline sets the earlier of the timeout and the deadline of any
+// bound context, recording in *isSet that a deadline was set for the
+// context.
+func (toc *timeoutConn) setDeadline(set func(time.Time) error, timeout time.Duration, isSet *bool) error {
+	if toc.ctx == nil {
+		if timeout != 0 {
+			set(time.Now().Add(timeout))
+		}
+		return nil
 	}
-	if opt.Timeout == 0 {
-		return w
+
+	var deadline time.Time
+	if timeout != 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if d, ok := toc.ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
 	}
-	if c, ok := w.(net.Conn); ok {
-		return &timeoutConn{
-			conn:         c,
-			readTimeout:  opt.Timeout,
-			writeTimeout: opt.Timeout,
+	if !deadline.IsZero() {
+		set(deadline)
+		*isSet = true
+	}
+
+	// Checked after setting the deadline, so that a cancellation cannot
+	// slip in between this check and the I/O: bind's goroutine expires
+	// the deadline after the context is done.
+	return toc.ctx.Err()
+}
+
+// bind bounds I/O on the connection by ctx until the returned function is
+// called. If ctx is cancelled, I/O in progress is interrupted by moving the
+// deadlines into the past. Unbinding clears only the deadlines set for ctx.
+func (toc *timeoutConn) bind(ctx context.Context) (unbind func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	toc.ctx = ctx
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	var expired bool
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			past := time.Unix(1, 0)
+			toc.conn.SetReadDeadline(past)
+			toc.conn.SetWriteDeadline(past)
+			expired = true
+		case <-stop:
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+		toc.ctx = nil
+		if toc.readSet || expired {
+			toc.conn.SetReadDeadline(time.Time{})
+		}
+		if toc.writeSet || expired {
+			toc.conn.SetWriteDeadline(time.Time{})
 		}
+		toc.readSet, toc.writeSet = false, false
 	}
-	return w
 }
 
-func timeoutReader(r io.Reader, opt *ReaderOptions) io.Reader {
-	if !opt.Bidirectional {
-		return r
+// contextErr returns ctx.Err() in place of an error caused by ctx
+// interrupting an operation.
+func contextErr(ctx context.Context, err error) error {
+	if err != nil {
+		if cerr := ctx.Err(); cerr != nil {
+			return cerr
+		}
+		// The connection deadline may expire just before the context's
+		// own timer fires.
+		if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+			return context.DeadlineExceeded
+		}
+	}
+	return err
+}
+
+func timeoutWriter(w io.Writer, opt *WriterOptions) io.Writer {
+	c, ok := w.(deadlineConn)
+	if !ok {
+		return w
 	}
-	if opt.Timeout == 0 {
+	toc := &timeoutConn{conn: c}
+	if opt.Bidirectional {
+		toc.readTimeout = opt.Timeout
+		toc.writeTimeout = opt.Timeout
+	}
+	return toc
+}
+
+func timeoutReader(r io.Reader, opt *ReaderOptions) io.Reader {
+	c, ok := r.(deadlineConn)
+	if !ok {
 		return r
 	}
-	if c, ok := r.(net.Conn); ok {
-		return &timeoutConn{
-			conn:         c,
-			readTimeout:  opt.Timeout,
-			writeTimeout: opt.Timeout,
-		}
+	toc := &timeoutConn{conn: c}
+	if opt.Bidirectional {
+		toc.readTimeout = opt.Timeout
+		toc.writeTimeout = opt.Timeout
 	}
-	return r
+	return toc
 }
 
 func disableReadTimeout(r io.Reader) {
-	if tc, ok := r.(*timeoutConn); ok {
+	if tc, ok := r.(*timeoutConn); ok && tc.readTimeout != 0 {
 		tc.readTimeout = 0
 		tc.conn.SetReadDeadline(time.Time{})
 	}